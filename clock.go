@@ -0,0 +1,260 @@
+package stdlib
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+type (
+	// Clock is the source of time used by the timestamp helpers. Tests replace it with a FakeClock via SetClock.
+	Clock interface {
+		Now() time.Time
+		Since(t time.Time) time.Duration
+		Sleep(d time.Duration)
+		After(d time.Duration) <-chan time.Time
+		NewTimer(d time.Duration) Timer
+		NewTicker(d time.Duration) Ticker
+	}
+
+	// Timer is the equivalent of time.Timer.
+	Timer interface {
+		C() <-chan time.Time
+		Stop() bool
+		Reset(d time.Duration) bool
+	}
+
+	// Ticker is the equivalent of time.Ticker.
+	Ticker interface {
+		C() <-chan time.Time
+		Stop()
+		Reset(d time.Duration)
+	}
+
+	// RealClock is the Clock based on package time.
+	RealClock struct{}
+
+	// FakeClock is a Clock that stands still until it is moved with Advance or Set.
+	// Timers and tickers fire when the clock passes their deadline.
+	FakeClock struct {
+		mu     sync.Mutex
+		now    time.Time
+		timers []*fakeTimer
+	}
+
+	realTimer struct {
+		*time.Timer
+	}
+
+	realTicker struct {
+		*time.Ticker
+	}
+
+	fakeTicker struct {
+		*fakeTimer
+	}
+
+	// fakeTimer is the Timer of a FakeClock, and with a period the state of a Ticker
+	fakeTimer struct {
+		clock    *FakeClock
+		c        chan time.Time
+		deadline time.Time
+		period   time.Duration
+		active   bool
+	}
+)
+
+var (
+	clockMu sync.RWMutex
+	clock   Clock = RealClock{}
+)
+
+// SetClock replaces the clock used by Now, Nano and ElapsedTimeSince, nil restores the RealClock.
+func SetClock(c Clock) {
+	clockMu.Lock()
+	defer clockMu.Unlock()
+
+	if c == nil {
+		c = RealClock{}
+	}
+	clock = c
+}
+
+// GetClock returns the clock set with SetClock.
+func GetClock() Clock {
+	clockMu.RLock()
+	defer clockMu.RUnlock()
+	return clock
+}
+
+func (RealClock) Now() time.Time                         { return time.Now() }
+func (RealClock) Since(t time.Time) time.Duration        { return time.Since(t) }
+func (RealClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+func (RealClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (RealClock) NewTimer(d time.Duration) Timer         { return realTimer{time.NewTimer(d)} }
+func (RealClock) NewTicker(d time.Duration) Ticker       { return realTicker{time.NewTicker(d)} }
+
+func (t realTimer) C() <-chan time.Time  { return t.Timer.C }
+func (t realTicker) C() <-chan time.Time { return t.Ticker.C }
+
+// NewFakeClock returns a clock standing at now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now implements Clock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Since implements Clock.
+func (c *FakeClock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// Sleep implements Clock, it blocks until the clock was advanced by d.
+func (c *FakeClock) Sleep(d time.Duration) {
+	<-c.After(d)
+}
+
+// After implements Clock.
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+// NewTimer implements Clock.
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{clock: c, c: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+// NewTicker implements Clock. Like time.NewTicker, it panics if d is not positive.
+func (c *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	t := fakeTicker{&fakeTimer{clock: c, c: make(chan time.Time, 1), period: d}}
+	t.Reset(d)
+	return t
+}
+
+// Advance moves the clock forward by d, firing the timers and tickers due on the way in order.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.advance(c.now.Add(d))
+}
+
+// Set moves the clock to now. Moving forward fires the timers and tickers due, moving back fires none.
+func (c *FakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.Before(c.now) {
+		c.now = now
+		return
+	}
+	c.advance(now)
+}
+
+// advance fires the timers due until target, one after another, and leaves the clock at target.
+func (c *FakeClock) advance(target time.Time) {
+	for {
+		var next *fakeTimer
+		for _, t := range c.timers {
+			if t.active && !t.deadline.After(target) && (next == nil || t.deadline.Before(next.deadline)) {
+				next = t
+			}
+		}
+		if next == nil {
+			break
+		}
+		if next.deadline.After(c.now) {
+			c.now = next.deadline
+		}
+		next.fire()
+	}
+	c.now = target
+
+	// forget timers that can not fire any more
+	active := c.timers[:0]
+	for _, t := range c.timers {
+		if t.active {
+			active = append(active, t)
+		}
+	}
+	clear(c.timers[len(active):])
+	c.timers = active
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+// Stop reports whether the timer was active.
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	active := t.active
+	t.active = false
+	t.drain()
+	return active
+}
+
+// Reset reports whether the timer was active.
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	active := t.active
+	t.drain()
+	t.deadline = t.clock.now.Add(d)
+	t.active = true
+	if !slices.Contains(t.clock.timers, t) {
+		t.clock.timers = append(t.clock.timers, t)
+	}
+	if d <= 0 {
+		t.fire() // already due
+	}
+	return active
+}
+
+func (t fakeTicker) Stop() {
+	t.fakeTimer.Stop()
+}
+
+// Reset panics if d is not positive, like time.Ticker.Reset.
+func (t fakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("non-positive interval for Ticker.Reset")
+	}
+	t.clock.mu.Lock()
+	t.period = d
+	t.clock.mu.Unlock()
+	t.fakeTimer.Reset(d)
+}
+
+// fire sends the deadline without blocking, like the runtime drops ticks of a slow receiver.
+func (t *fakeTimer) fire() {
+	select {
+	case t.c <- t.deadline:
+	default:
+	}
+	if t.period > 0 {
+		t.deadline = t.deadline.Add(t.period)
+	} else {
+		t.active = false
+	}
+}
+
+// drain drops a value not received yet, like Stop and Reset of a time.Timer since Go 1.23.
+func (t *fakeTimer) drain() {
+	select {
+	case <-t.c:
+	default:
+	}
+}
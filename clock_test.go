@@ -0,0 +1,146 @@
+package stdlib
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetClock(t *testing.T) {
+	clock := NewFakeClock(time.Unix(knownNow, 0))
+	SetClock(clock)
+	defer SetClock(nil)
+
+	assert.Equal(t, clock, GetClock())
+	assert.Equal(t, int64(knownNow), Now())
+	assert.Equal(t, int64(knownNow)*int64(time.Second), Nano())
+
+	start := clock.Now()
+	clock.Advance(1500 * time.Millisecond)
+	assert.Equal(t, int64(1500), ElapsedTimeSince(start))
+	assert.Equal(t, int64(knownNow+1), Now())
+
+	SetClock(nil)
+	assert.Equal(t, RealClock{}, GetClock())
+	assert.Greater(t, Now(), int64(knownNow))
+}
+
+func TestFakeClockSet(t *testing.T) {
+	clock := NewFakeClock(time.Unix(knownNow, 0))
+
+	clock.Set(time.Unix(knownNow+60, 0))
+	assert.Equal(t, time.Minute, clock.Since(time.Unix(knownNow, 0)))
+
+	// back in time
+	timer := clock.NewTimer(time.Second)
+	clock.Set(time.Unix(knownNow, 0))
+	assert.Equal(t, int64(knownNow), clock.Now().Unix())
+	assert.Len(t, timer.C(), 0)
+
+	clock.Set(time.Unix(knownNow+61, 0))
+	assert.Len(t, timer.C(), 1)
+}
+
+func TestFakeTimer(t *testing.T) {
+	start := time.Unix(knownNow, 0)
+	clock := NewFakeClock(start)
+
+	timer := clock.NewTimer(10 * time.Second)
+	clock.Advance(9 * time.Second)
+	select {
+	case <-timer.C():
+		assert.Fail(t, "fired early")
+	default:
+	}
+
+	clock.Advance(5 * time.Second)
+	assert.Equal(t, start.Add(10*time.Second), <-timer.C())
+	assert.False(t, timer.Stop())
+
+	// reset and stop
+	assert.False(t, timer.Reset(time.Second))
+	assert.True(t, timer.Stop())
+	clock.Advance(time.Minute)
+	assert.Len(t, timer.C(), 0)
+
+	// a pending value is dropped by Reset
+	timer.Reset(time.Second)
+	clock.Advance(time.Second)
+	timer.Reset(time.Second)
+	assert.Len(t, timer.C(), 0)
+
+	// already due
+	assert.Len(t, clock.After(0), 1)
+
+	// sleep returns once the clock was advanced
+	done := make(chan struct{})
+	go func() {
+		clock.Sleep(time.Hour)
+		close(done)
+	}()
+	assert.Eventually(t, func() bool {
+		clock.Advance(time.Minute)
+		select {
+		case <-done:
+			return true
+		default:
+			return false
+		}
+	}, time.Second, time.Millisecond)
+}
+
+func TestFakeTicker(t *testing.T) {
+	start := time.Unix(knownNow, 0)
+	clock := NewFakeClock(start)
+
+	ticker := clock.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	// timers fire in order of their deadlines, with the clock at the deadline
+	var fired []time.Time
+	timer := clock.NewTimer(1500 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		clock.Advance(time.Second)
+		select {
+		case ts := <-ticker.C():
+			fired = append(fired, ts)
+		default:
+		}
+	}
+	assert.Equal(t, []time.Time{start.Add(time.Second), start.Add(2 * time.Second), start.Add(3 * time.Second)}, fired)
+	assert.Equal(t, start.Add(1500*time.Millisecond), <-timer.C())
+
+	// ticks are dropped if nobody receives them
+	clock.Advance(10 * time.Second)
+	assert.Equal(t, start.Add(4*time.Second), <-ticker.C())
+	assert.Len(t, ticker.C(), 0)
+
+	ticker.Reset(time.Minute)
+	clock.Advance(59 * time.Second)
+	assert.Len(t, ticker.C(), 0)
+	clock.Advance(time.Second)
+	assert.Len(t, ticker.C(), 1)
+
+	ticker.Stop()
+	clock.Advance(time.Hour)
+	assert.Len(t, ticker.C(), 0)
+
+	assert.Panics(t, func() { clock.NewTicker(0) })
+	assert.Panics(t, func() { ticker.Reset(-time.Second) })
+}
+
+func TestRealClock(t *testing.T) {
+	clock := RealClock{}
+	start := clock.Now()
+
+	timer := clock.NewTimer(time.Millisecond)
+	<-timer.C()
+	ticker := clock.NewTicker(time.Millisecond)
+	<-ticker.C()
+	ticker.Stop()
+	<-clock.After(time.Millisecond)
+	clock.Sleep(time.Millisecond)
+
+	assert.GreaterOrEqual(t, clock.Since(start), 4*time.Millisecond)
+}
@@ -7,6 +7,7 @@ toolchain go1.24.6
 require (
 	github.com/Bytom/bytom v1.1.1
 	github.com/PuerkitoBio/rehttp v1.4.0
+	github.com/klauspost/compress v1.18.0
 	github.com/miguelmota/go-ethereum-hdwallet v0.1.3
 	github.com/rs/xid v1.6.0
 	github.com/rs/zerolog v1.34.0
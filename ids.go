@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
+	"hash"
 	"hash/crc32"
 	"io"
 )
@@ -25,6 +26,16 @@ func Fingerprint(a string) string {
 	return hex.EncodeToString(hash[:])
 }
 
+// NewChecksum returns the hash behind Checksum, e.g. to checksum a stream. Its hex encoded sum equals Checksum.
+func NewChecksum() hash.Hash32 {
+	return crc32.New(crc32q)
+}
+
+// NewFingerprint returns the hash behind Fingerprint, e.g. to fingerprint a stream. Its hex encoded sum equals Fingerprint.
+func NewFingerprint() hash.Hash {
+	return md5.New()
+}
+
 // UUID generates a random UUID according to RFC 4122
 func UUID() (string, error) {
 	uuid := make([]byte, 16)
@@ -1,6 +1,8 @@
 package stdlib
 
 import (
+	"encoding/hex"
+	"io"
 	"strings"
 	"testing"
 
@@ -33,6 +35,19 @@ func TestFingerprint(t *testing.T) {
 	assert.Equal(t, result2, cs2)
 }
 
+func TestStreamingChecksums(t *testing.T) {
+	cs := NewChecksum()
+	fp := NewFingerprint()
+
+	for _, part := range []string{"check", " ", "me"} {
+		io.WriteString(cs, part)
+		io.WriteString(fp, part)
+	}
+
+	assert.Equal(t, Checksum("check me"), hex.EncodeToString(cs.Sum(nil)))
+	assert.Equal(t, Fingerprint("check me"), hex.EncodeToString(fp.Sum(nil)))
+}
+
 func TestUUID(t *testing.T) {
 	uuid, err := UUID()
 
@@ -0,0 +1,240 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+const (
+	// DefaultBatchConcurrency limits the calls Batch runs at the same time if BatchOptions.Concurrency is not set
+	DefaultBatchConcurrency = 8
+	// DefaultBatchSize limits the requests combined into one call to a batch endpoint if BatchOptions.BatchSize is not set
+	DefaultBatchSize = 100
+)
+
+type (
+	// BatchResult is the outcome of one request of a batch.
+	BatchResult[T any] struct {
+		Index    int // position of the request in the batch
+		Request  *Request
+		Value    T
+		Response *Response
+		Err      error
+	}
+
+	// BatchItem is the reply to one request of a call to a batch endpoint.
+	BatchItem struct {
+		StatusCode int
+		Body       json.RawMessage
+	}
+
+	// BatchEncoder combines requests into one call to a server-side batch endpoint and splits its reply.
+	BatchEncoder interface {
+		Encode(reqs []*Request) (*Request, error)
+		// Decode returns one item per request, in the order of the requests
+		Decode(body json.RawMessage, reqs []*Request) ([]BatchItem, error)
+	}
+
+	// BatchOptions controls how Batch executes the requests.
+	BatchOptions struct {
+		Concurrency int          // DefaultBatchConcurrency if not set
+		Encoder     BatchEncoder // sends the requests to a batch endpoint instead of one by one, if set
+		BatchSize   int          // DefaultBatchSize if not set, only used with an Encoder
+	}
+
+	// JSONBatchEncoder sends requests as a JSON array of sub-requests to Path and expects a JSON array
+	// of replies, e.g. [{"id":"0","method":"GET","path":"/users/1"}] and [{"id":"0","status":200,"body":{...}}].
+	// Replies are matched by id, or by position if they have none.
+	JSONBatchEncoder struct {
+		Path string
+	}
+
+	jsonSubRequest struct {
+		ID      string            `json:"id"`
+		Method  string            `json:"method"`
+		Path    string            `json:"path"`
+		Headers map[string]string `json:"headers,omitempty"`
+		Body    interface{}       `json:"body,omitempty"`
+	}
+
+	jsonSubResponse struct {
+		ID     string          `json:"id,omitempty"`
+		Status int             `json:"status"`
+		Body   json.RawMessage `json:"body,omitempty"`
+	}
+)
+
+// Batch executes reqs with bounded concurrency and returns one result per request, in the order of reqs.
+// Every call passes the client's rate limiter, retries and logging. Requests not started when ctx is done fail with ctx.Err().
+func Batch[T any](ctx context.Context, c *RestClient, reqs []*Request, opts BatchOptions) []BatchResult[T] {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultBatchConcurrency
+	}
+
+	results := make([]BatchResult[T], len(reqs))
+	for i, r := range reqs {
+		results[i].Index = i
+		results[i].Request = r
+	}
+
+	// one job per request, or per chunk of requests sent to a batch endpoint
+	var jobs [][]*BatchResult[T]
+	size := 1
+	if opts.Encoder != nil {
+		size = opts.BatchSize
+		if size <= 0 {
+			size = DefaultBatchSize
+		}
+	}
+	for i := 0; i < len(results); i += size {
+		job := make([]*BatchResult[T], 0, size)
+		for j := i; j < len(results) && j < i+size; j++ {
+			job = append(job, &results[j])
+		}
+		jobs = append(jobs, job)
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	for _, job := range jobs {
+		select {
+		case <-ctx.Done():
+		case sem <- struct{}{}:
+		}
+		if ctx.Err() != nil {
+			for _, res := range job {
+				res.Response = &Response{StatusCode: http.StatusInternalServerError}
+				res.Err = &Error{StatusCode: http.StatusInternalServerError, Err: ctx.Err()}
+			}
+			continue
+		}
+
+		wg.Add(1)
+		go func(job []*BatchResult[T]) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if opts.Encoder != nil {
+				callBatch(ctx, c, opts.Encoder, job)
+			} else {
+				res := job[0]
+				res.Response, res.Err = c.Call(ctx, res.Request, &res.Value)
+			}
+		}(job)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// callBatch sends the requests of job to a batch endpoint and distributes the replies.
+func callBatch[T any](ctx context.Context, c *RestClient, encoder BatchEncoder, job []*BatchResult[T]) {
+	reqs := make([]*Request, len(job))
+	for i, res := range job {
+		reqs[i] = res.Request
+	}
+
+	fail := func(meta *Response, err error) {
+		for _, res := range job {
+			res.Response, res.Err = meta, err
+		}
+	}
+
+	batch, err := encoder.Encode(reqs)
+	if err != nil {
+		fail(&Response{StatusCode: http.StatusBadRequest}, &Error{StatusCode: http.StatusBadRequest, Err: err})
+		return
+	}
+
+	var body json.RawMessage
+	meta, err := c.Call(ctx, batch, &body)
+	if err != nil {
+		fail(meta, err)
+		return
+	}
+
+	items, err := encoder.Decode(body, reqs)
+	if err == nil && len(items) != len(job) {
+		err = fmt.Errorf("expected %d batch items, got %d", len(job), len(items))
+	}
+	if err != nil {
+		fail(meta, &Error{StatusCode: meta.StatusCode, RequestID: meta.RequestID, Err: err})
+		return
+	}
+
+	for i, res := range job {
+		item := items[i]
+		res.Response = &Response{
+			StatusCode: item.StatusCode,
+			Header:     meta.Header,
+			Duration:   meta.Duration,
+			Attempts:   meta.Attempts,
+			RequestID:  meta.RequestID,
+
+			ServerRequestID: meta.ServerRequestID,
+		}
+
+		// as in roundTrip, anything other than OK, Created, Accepted, NoContent is an error
+		if item.StatusCode > http.StatusNoContent {
+			res.Err = &Error{StatusCode: item.StatusCode, RequestID: meta.RequestID, Message: string(item.Body)}
+			continue
+		}
+		if len(item.Body) > 0 && item.StatusCode != http.StatusNoContent {
+			if err := json.Unmarshal(item.Body, &res.Value); err != nil {
+				res.Err = &Error{StatusCode: item.StatusCode, RequestID: meta.RequestID, Err: err}
+			}
+		}
+	}
+}
+
+// Encode implements BatchEncoder.
+func (e *JSONBatchEncoder) Encode(reqs []*Request) (*Request, error) {
+	subs := make([]jsonSubRequest, len(reqs))
+	for i, r := range reqs {
+		path, err := r.URL("")
+		if err != nil {
+			return nil, err
+		}
+		subs[i] = jsonSubRequest{
+			ID:     strconv.Itoa(i),
+			Method: r.Method,
+			Path:   path,
+			Body:   r.Body,
+		}
+		if len(r.Header) > 0 {
+			subs[i].Headers = make(map[string]string, len(r.Header))
+			for k := range r.Header {
+				subs[i].Headers[k] = r.Header.Get(k)
+			}
+		}
+	}
+	return NewRequest(http.MethodPost, e.Path).SetBody(subs), nil
+}
+
+// Decode implements BatchEncoder.
+func (e *JSONBatchEncoder) Decode(body json.RawMessage, reqs []*Request) ([]BatchItem, error) {
+	var subs []jsonSubResponse
+	if err := json.Unmarshal(body, &subs); err != nil {
+		return nil, err
+	}
+
+	items := make([]BatchItem, len(subs))
+	for i, sub := range subs {
+		pos := i
+		if sub.ID != "" {
+			id, err := strconv.Atoi(sub.ID)
+			if err != nil || id < 0 || id >= len(subs) {
+				return nil, fmt.Errorf("unknown batch item id '%s'", sub.ID)
+			}
+			pos = id
+		}
+		items[pos] = BatchItem{StatusCode: sub.Status, Body: sub.Body}
+	}
+	return items, nil
+}
@@ -0,0 +1,142 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatch(t *testing.T) {
+	var running, peak atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		if strings.HasSuffix(r.URL.Path, "/13") {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, `{"id":"%s"}`, strings.TrimPrefix(r.URL.Path, "/users/"))
+	}))
+	defer srv.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL))
+	assert.NoError(t, err)
+
+	reqs := make([]*Request, 20)
+	for i := range reqs {
+		reqs[i] = NewRequest(http.MethodGet, "/users/{id}").SetParam("id", fmt.Sprint(i))
+	}
+
+	results := Batch[testUser](context.TODO(), cl, reqs, BatchOptions{Concurrency: 4})
+	assert.Len(t, results, 20)
+	assert.LessOrEqual(t, peak.Load(), int32(4))
+
+	for i, res := range results {
+		assert.Equal(t, i, res.Index)
+		assert.Same(t, reqs[i], res.Request)
+		if i == 13 {
+			var apiErr *Error
+			assert.True(t, errors.As(res.Err, &apiErr))
+			assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+			continue
+		}
+		assert.NoError(t, res.Err)
+		assert.Equal(t, fmt.Sprint(i), res.Value.ID)
+		assert.Equal(t, http.StatusOK, res.Response.StatusCode)
+	}
+
+	// nothing starts once ctx is done
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	for _, res := range Batch[testUser](ctx, cl, reqs, BatchOptions{}) {
+		assert.ErrorIs(t, res.Err, context.Canceled)
+	}
+}
+
+func TestBatchWithRateLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL), WithRateLimit(100, 1))
+	assert.NoError(t, err)
+
+	reqs := make([]*Request, 6)
+	for i := range reqs {
+		reqs[i] = NewRequest(http.MethodGet, "/")
+	}
+
+	start := time.Now()
+	for _, res := range Batch[map[string]any](context.TODO(), cl, reqs, BatchOptions{Concurrency: 6}) {
+		assert.NoError(t, res.Err)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 45*time.Millisecond)
+}
+
+func TestBatchEndpoint(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/batch", r.URL.Path)
+
+		var subs []jsonSubRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&subs))
+
+		// replies in reverse order, matched by id
+		replies := make([]jsonSubResponse, 0, len(subs))
+		for i := len(subs) - 1; i >= 0; i-- {
+			sub := subs[i]
+			if sub.Path == "/users/missing" {
+				replies = append(replies, jsonSubResponse{ID: sub.ID, Status: http.StatusNotFound, Body: json.RawMessage(`"not found"`)})
+				continue
+			}
+			assert.Equal(t, "yes", sub.Headers["X-Test"])
+			body, _ := json.Marshal(map[string]string{"id": strings.TrimPrefix(sub.Path, "/users/"), "name": sub.Method})
+			replies = append(replies, jsonSubResponse{ID: sub.ID, Status: http.StatusOK, Body: body})
+		}
+		json.NewEncoder(w).Encode(replies)
+	}))
+	defer srv.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL))
+	assert.NoError(t, err)
+
+	reqs := []*Request{
+		NewRequest(http.MethodGet, "/users/1").SetHeader("X-Test", "yes"),
+		NewRequest(http.MethodGet, "/users/missing"),
+		NewRequest(http.MethodPut, "/users/3").SetHeader("X-Test", "yes").SetBody(testUser{Name: "carol"}),
+	}
+
+	results := Batch[testUser](context.TODO(), cl, reqs, BatchOptions{Encoder: &JSONBatchEncoder{Path: "/batch"}, BatchSize: 2})
+	assert.Equal(t, int32(2), calls.Load())
+
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, testUser{ID: "1", Name: "GET"}, results[0].Value)
+
+	var apiErr *Error
+	assert.True(t, errors.As(results[1].Err, &apiErr))
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	assert.Equal(t, http.StatusNotFound, results[1].Response.StatusCode)
+
+	assert.NoError(t, results[2].Err)
+	assert.Equal(t, testUser{ID: "3", Name: "PUT"}, results[2].Value)
+}
@@ -0,0 +1,164 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	BreakerClosed   BreakerState = iota // requests pass, failures are counted
+	BreakerOpen                         // requests fail immediately until the cool-down has passed
+	BreakerHalfOpen                     // a single probe request decides whether to close or open again
+
+	// DefaultBreakerMinRequests is the number of requests in a window before the failure ratio is evaluated
+	DefaultBreakerMinRequests = 5
+)
+
+type (
+	BreakerState int
+
+	// CircuitBreaker is a RoundTripper that stops sending requests to a backend once the ratio
+	// of failed requests (errors and 5xx) within Window exceeds FailureRatio. After Cooldown
+	// a single request probes the backend again.
+	CircuitBreaker struct {
+		InnerTransport http.RoundTripper
+		FailureRatio   float64
+		Window         time.Duration
+		Cooldown       time.Duration
+		MinRequests    int
+
+		mu          sync.Mutex
+		state       BreakerState
+		windowStart time.Time
+		requests    int
+		failures    int
+		openedAt    time.Time
+		probing     bool
+	}
+)
+
+var (
+	// ErrCircuitOpen is returned without contacting the backend while the circuit breaker is open
+	ErrCircuitOpen = errors.New("circuit breaker is open")
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// NewCircuitBreaker wraps transport with a circuit breaker.
+func NewCircuitBreaker(transport http.RoundTripper, ratio float64, window, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		InnerTransport: transport,
+		FailureRatio:   ratio,
+		Window:         window,
+		Cooldown:       cooldown,
+		MinRequests:    DefaultBreakerMinRequests,
+		windowStart:    time.Now(),
+	}
+}
+
+// State returns the current state of the circuit breaker.
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= b.Cooldown {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// RoundTrip fails fast with ErrCircuitOpen while the breaker is open.
+func (b *CircuitBreaker) RoundTrip(req *http.Request) (*http.Response, error) {
+	probe, err := b.before(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := b.InnerTransport.RoundTrip(req)
+	b.after(req, probe, err != nil || (resp != nil && resp.StatusCode >= http.StatusInternalServerError))
+
+	return resp, err
+}
+
+func (b *CircuitBreaker) before(req *http.Request) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.Cooldown {
+			return false, ErrCircuitOpen
+		}
+		b.setState(BreakerHalfOpen, req)
+		fallthrough
+	case BreakerHalfOpen:
+		if b.probing {
+			return false, ErrCircuitOpen
+		}
+		b.probing = true
+		return true, nil
+	}
+
+	if now := time.Now(); now.Sub(b.windowStart) >= b.Window {
+		b.windowStart = now
+		b.requests = 0
+		b.failures = 0
+	}
+	return false, nil
+}
+
+func (b *CircuitBreaker) after(req *http.Request, probe, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if probe {
+		b.probing = false
+		if failed {
+			b.open(req)
+		} else {
+			b.setState(BreakerClosed, req)
+			b.windowStart = time.Now()
+			b.requests = 0
+			b.failures = 0
+		}
+		return
+	}
+	if b.state != BreakerClosed {
+		return
+	}
+
+	b.requests++
+	if failed {
+		b.failures++
+	}
+	if b.requests >= b.MinRequests && float64(b.failures)/float64(b.requests) >= b.FailureRatio {
+		b.open(req)
+	}
+}
+
+func (b *CircuitBreaker) open(req *http.Request) {
+	b.openedAt = time.Now()
+	b.setState(BreakerOpen, req)
+}
+
+func (b *CircuitBreaker) setState(state BreakerState, req *http.Request) {
+	if b.state == state {
+		return
+	}
+	log.Warn().Str("host", req.URL.Host).Str("from", b.state.String()).Str("to", state.String()).Int("requests", b.requests).Int("failures", b.failures).Msg("BREAKER")
+	b.state = state
+}
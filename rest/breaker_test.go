@@ -0,0 +1,92 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusInternalServerError)
+
+	inner := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: int(status.Load()), Body: http.NoBody, Request: req}, nil
+	})
+
+	cb := NewCircuitBreaker(inner, 0.5, time.Minute, 50*time.Millisecond)
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+
+	for i := 0; i < DefaultBreakerMinRequests; i++ {
+		assert.Equal(t, BreakerClosed, cb.State())
+		_, err := cb.RoundTrip(req)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, BreakerOpen, cb.State())
+
+	_, err := cb.RoundTrip(req)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+
+	// the probe fails and opens the breaker again
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, BreakerHalfOpen, cb.State())
+	_, err = cb.RoundTrip(req)
+	assert.NoError(t, err)
+	assert.Equal(t, BreakerOpen, cb.State())
+
+	// the probe succeeds and closes the breaker
+	status.Store(http.StatusOK)
+	time.Sleep(60 * time.Millisecond)
+	_, err = cb.RoundTrip(req)
+	assert.NoError(t, err)
+	assert.Equal(t, BreakerClosed, cb.State())
+}
+
+func TestCircuitBreakerTransportErrors(t *testing.T) {
+	inner := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	})
+
+	cb := NewCircuitBreaker(inner, 1, time.Minute, time.Minute)
+	cb.MinRequests = 2
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+
+	_, _ = cb.RoundTrip(req)
+	_, _ = cb.RoundTrip(req)
+	_, err := cb.RoundTrip(req)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+}
+
+func TestWithCircuitBreaker(t *testing.T) {
+	var calls atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL), WithCircuitBreaker(0.5, time.Minute, time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, "0.5,1m0s,1m0s", cl.Settings.GetOption(OptionCircuitBreaker))
+
+	for i := 0; i < DefaultBreakerMinRequests; i++ {
+		_, err := cl.GET("/", nil)
+		assert.Error(t, err)
+	}
+	_, err = cl.GET("/", nil)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, int32(DefaultBreakerMinRequests), calls.Load())
+}
@@ -0,0 +1,348 @@
+package rest
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/txsvc/stdlib/v2"
+)
+
+const (
+	// XFromCache is set on responses that were served from the cache
+	XFromCache = "X-From-Cache"
+
+	// xVaried prefixes the request headers a cached response varies by, stored along with it
+	xVaried = "X-Varied-"
+
+	// DefaultCacheSize is the number of responses kept by a MemoryCache of size 0
+	DefaultCacheSize = 1000
+)
+
+type (
+	// Cache stores serialized responses by key.
+	Cache interface {
+		Get(key string) ([]byte, bool)
+		Set(key string, data []byte)
+		Delete(key string)
+	}
+
+	// MemoryCache is a Cache that keeps the most recently used responses in memory.
+	MemoryCache struct {
+		mu    sync.Mutex
+		size  int
+		ll    *list.List
+		items map[string]*list.Element
+	}
+
+	// DiskCache is a Cache that keeps responses as files in a directory.
+	DiskCache struct {
+		dir string
+	}
+
+	// CachingTransport is a RoundTripper that serves GET requests from its cache while they are fresh
+	// according to Cache-Control/Expires and revalidates them using If-None-Match/If-Modified-Since.
+	// A response is only served to requests with the same values of the headers named in its Vary header.
+	CachingTransport struct {
+		InnerTransport http.RoundTripper
+		Cache          Cache
+	}
+
+	cacheEntry struct {
+		key  string
+		data []byte
+	}
+)
+
+// NewMemoryCache returns a LRU cache holding up to size responses.
+func NewMemoryCache(size int) *MemoryCache {
+	if size <= 0 {
+		size = DefaultCacheSize
+	}
+	return &MemoryCache{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+func (c *MemoryCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.items[key]; ok {
+		c.ll.MoveToFront(e)
+		return e.Value.(*cacheEntry).data, true
+	}
+	return nil, false
+}
+
+func (c *MemoryCache) Set(key string, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.items[key]; ok {
+		c.ll.MoveToFront(e)
+		e.Value.(*cacheEntry).data = data
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&cacheEntry{key: key, data: data})
+	if c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+func (c *MemoryCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.items[key]; ok {
+		c.ll.Remove(e)
+		delete(c.items, key)
+	}
+}
+
+// Len returns the number of cached responses.
+func (c *MemoryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.ll.Len()
+}
+
+// NewDiskCache returns a cache that stores its responses in dir. The directory is created when needed.
+func NewDiskCache(dir string) *DiskCache {
+	return &DiskCache{dir: dir}
+}
+
+func (c *DiskCache) Get(key string) ([]byte, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+func (c *DiskCache) Set(key string, data []byte) {
+	if err := os.MkdirAll(c.dir, os.ModePerm); err != nil {
+		return
+	}
+
+	// write and rename, readers never see a partial file
+	tmp, err := os.CreateTemp(c.dir, "tmp-*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return
+	}
+	_ = os.Rename(tmp.Name(), c.path(key))
+}
+
+func (c *DiskCache) Delete(key string) {
+	_ = os.Remove(c.path(key))
+}
+
+func (c *DiskCache) path(key string) string {
+	return filepath.Join(c.dir, stdlib.Fingerprint(key))
+}
+
+// NewCachingTransport wraps transport with a response cache.
+func NewCachingTransport(transport http.RoundTripper, cache Cache) *CachingTransport {
+	return &CachingTransport{
+		InnerTransport: transport,
+		Cache:          cache,
+	}
+}
+
+// RoundTrip serves fresh responses from the cache and revalidates stale ones.
+func (t *CachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqCC := parseCacheControl(req.Header)
+	if req.Method != http.MethodGet || reqCC.has("no-store") || isOpened(req) {
+		return t.InnerTransport.RoundTrip(req)
+	}
+
+	key := cacheKey(req)
+	cached := t.load(key, req)
+
+	outgoing := req
+	if cached != nil {
+		if !reqCC.has("no-cache") && isFresh(cached.Header, time.Now()) {
+			cached.Header.Set(XFromCache, "1")
+			return cached, nil
+		}
+
+		etag := cached.Header.Get("ETag")
+		lastModified := cached.Header.Get("Last-Modified")
+		if etag != "" || lastModified != "" {
+			outgoing = req.Clone(req.Context())
+			if etag != "" {
+				outgoing.Header.Set("If-None-Match", etag)
+			}
+			if lastModified != "" {
+				outgoing.Header.Set("If-Modified-Since", lastModified)
+			}
+		}
+	}
+
+	resp, err := t.InnerTransport.RoundTrip(outgoing)
+	if err != nil {
+		return resp, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		_ = resp.Body.Close()
+
+		// the 304 carries the updated validators and freshness
+		for _, h := range []string{"Date", "Cache-Control", "Expires", "ETag", "Last-Modified"} {
+			if v := resp.Header.Get(h); v != "" {
+				cached.Header.Set(h, v)
+			}
+		}
+		t.store(key, req, cached)
+
+		cached.Header.Set(XFromCache, "1")
+		return cached, nil
+	}
+
+	if resp.StatusCode == http.StatusOK && isCacheable(resp.Header) {
+		t.store(key, req, resp)
+	} else if cached != nil && resp.StatusCode < http.StatusInternalServerError {
+		t.Cache.Delete(key)
+	}
+	return resp, nil
+}
+
+func (t *CachingTransport) load(key string, req *http.Request) *http.Response {
+	data, ok := t.Cache.Get(key)
+	if !ok {
+		return nil
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), req)
+	if err != nil {
+		t.Cache.Delete(key)
+		return nil
+	}
+
+	// a response for other values of the headers it varies by is a miss, the new response replaces it
+	for _, field := range varyFields(resp.Header) {
+		if resp.Header.Get(xVaried+field) != req.Header.Get(field) {
+			_ = resp.Body.Close()
+			return nil
+		}
+		resp.Header.Del(xVaried + field)
+	}
+	return resp
+}
+
+func (t *CachingTransport) store(key string, req *http.Request, resp *http.Response) {
+	if resp.Header.Get("Date") == "" {
+		resp.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	}
+	resp.Header.Del(XFromCache)
+
+	for _, field := range varyFields(resp.Header) {
+		resp.Header.Set(xVaried+field, req.Header.Get(field))
+	}
+	defer func() {
+		for _, field := range varyFields(resp.Header) {
+			resp.Header.Del(xVaried + field)
+		}
+	}()
+
+	// DumpResponse restores the body after reading it
+	data, err := httputil.DumpResponse(resp, true)
+	if err != nil {
+		return
+	}
+	t.Cache.Set(key, data)
+}
+
+// cacheKey separates the entries of different credentials using the same cache.
+func cacheKey(req *http.Request) string {
+	if auth := req.Header.Get("Authorization"); auth != "" {
+		return req.URL.String() + " " + stdlib.Fingerprint(auth)
+	}
+	return req.URL.String()
+}
+
+type cacheControl map[string]string
+
+func parseCacheControl(h http.Header) cacheControl {
+	cc := cacheControl{}
+	for _, value := range h.Values("Cache-Control") {
+		for _, part := range strings.Split(value, ",") {
+			k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if k != "" {
+				cc[strings.ToLower(k)] = strings.Trim(v, `"`)
+			}
+		}
+	}
+	return cc
+}
+
+func (cc cacheControl) has(directive string) bool {
+	_, ok := cc[directive]
+	return ok
+}
+
+func isCacheable(h http.Header) bool {
+	cc := parseCacheControl(h)
+	if cc.has("no-store") || isStream(h) || slices.Contains(varyFields(h), "*") {
+		return false
+	}
+	return cc.has("max-age") || h.Get("Expires") != "" || h.Get("ETag") != "" || h.Get("Last-Modified") != ""
+}
+
+// varyFields returns the canonical names of the request headers listed in Vary.
+func varyFields(h http.Header) []string {
+	var fields []string
+	for _, value := range h.Values("Vary") {
+		for _, field := range strings.Split(value, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				fields = append(fields, http.CanonicalHeaderKey(field))
+			}
+		}
+	}
+	return fields
+}
+
+func isFresh(h http.Header, now time.Time) bool {
+	cc := parseCacheControl(h)
+	if cc.has("no-cache") {
+		return false
+	}
+
+	date, err := http.ParseTime(h.Get("Date"))
+	if err != nil {
+		return false
+	}
+	if maxAge, ok := cc["max-age"]; ok {
+		secs, err := strconv.Atoi(maxAge)
+		if err != nil {
+			return false
+		}
+		return now.Sub(date) < time.Duration(secs)*time.Second
+	}
+	if expires, err := http.ParseTime(h.Get("Expires")); err == nil {
+		return now.Before(expires)
+	}
+	return false
+}
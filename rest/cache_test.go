@@ -0,0 +1,163 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryCache(t *testing.T) {
+	c := NewMemoryCache(2)
+
+	c.Set("a", []byte("1"))
+	c.Set("b", []byte("2"))
+	_, _ = c.Get("a") // b is now the least recently used
+	c.Set("c", []byte("3"))
+
+	assert.Equal(t, 2, c.Len())
+	_, ok := c.Get("b")
+	assert.False(t, ok)
+
+	data, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("1"), data)
+
+	c.Delete("a")
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+}
+
+func TestDiskCache(t *testing.T) {
+	c := NewDiskCache(t.TempDir() + "/cache")
+
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+
+	c.Set("a", []byte("1"))
+	data, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("1"), data)
+
+	c.Delete("a")
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+}
+
+func TestCachingTransport(t *testing.T) {
+	var calls, notModified atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/etag":
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				notModified.Add(1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/modified":
+			w.Header().Set("Last-Modified", "Mon, 19 Oct 2026 10:00:00 GMT")
+			if r.Header.Get("If-Modified-Since") != "" {
+				notModified.Add(1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/nostore":
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		_, _ = w.Write([]byte(`{"path":"` + r.URL.Path + `"}`))
+	}))
+	defer srv.Close()
+
+	for _, cache := range []Cache{NewMemoryCache(10), NewDiskCache(t.TempDir())} {
+		calls.Store(0)
+		notModified.Store(0)
+
+		cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL), WithCache(cache))
+		assert.NoError(t, err)
+
+		for _, path := range []string{"/fresh", "/etag", "/modified", "/nostore"} {
+			for i := 0; i < 2; i++ {
+				resp := map[string]string{}
+				meta, err := cl.Call(context.TODO(), NewRequest("GET", path), &resp)
+				assert.NoError(t, err)
+				assert.Equal(t, http.StatusOK, meta.StatusCode)
+				assert.Equal(t, path, resp["path"])
+			}
+		}
+
+		// /fresh only once, /etag and /modified revalidated, /nostore twice
+		assert.Equal(t, int32(7), calls.Load())
+		assert.Equal(t, int32(2), notModified.Load())
+	}
+}
+
+func TestCachingTransportVary(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		if r.URL.Path == "/any" {
+			w.Header().Set("Vary", "*")
+		} else {
+			w.Header().Set("Vary", "Accept-Language, x-tenant")
+		}
+		_, _ = w.Write([]byte(`{"lang":"` + r.Header.Get("Accept-Language") + `","tenant":"` + r.Header.Get("X-Tenant") + `"}`))
+	}))
+	defer srv.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL), WithCache(NewMemoryCache(10)))
+	assert.NoError(t, err)
+
+	get := func(path, lang, tenant string) (map[string]string, *Response) {
+		resp := map[string]string{}
+		meta, err := cl.Call(context.TODO(), NewRequest("GET", path).SetHeader("Accept-Language", lang).SetHeader("X-Tenant", tenant), &resp)
+		assert.NoError(t, err)
+		return resp, meta
+	}
+
+	resp, _ := get("/vary", "en", "a")
+	assert.Equal(t, "en", resp["lang"])
+	resp, meta := get("/vary", "en", "a")
+	assert.Equal(t, "en", resp["lang"])
+	assert.Equal(t, "1", meta.Header.Get(XFromCache))
+	assert.Empty(t, meta.Header.Get("X-Varied-Accept-Language"))
+	assert.Equal(t, int32(1), calls.Load())
+
+	// other values are not served the cached response
+	resp, _ = get("/vary", "de", "a")
+	assert.Equal(t, "de", resp["lang"])
+	resp, _ = get("/vary", "de", "b")
+	assert.Equal(t, "b", resp["tenant"])
+	assert.Equal(t, int32(3), calls.Load())
+
+	// Vary: * is never cached
+	get("/any", "en", "a")
+	get("/any", "en", "a")
+	assert.Equal(t, int32(5), calls.Load())
+}
+
+func TestIsFresh(t *testing.T) {
+	h := http.Header{}
+	h.Set("Date", "Mon, 19 Oct 2026 10:00:00 GMT")
+	h.Set("Cache-Control", "public, max-age=60")
+
+	now, _ := http.ParseTime("Mon, 19 Oct 2026 10:00:30 GMT")
+	assert.True(t, isFresh(h, now))
+
+	now, _ = http.ParseTime("Mon, 19 Oct 2026 10:01:30 GMT")
+	assert.False(t, isFresh(h, now))
+
+	h.Del("Cache-Control")
+	h.Set("Expires", "Mon, 19 Oct 2026 11:00:00 GMT")
+	assert.True(t, isFresh(h, now))
+}
@@ -0,0 +1,188 @@
+package rest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+	EncodingZstd    = "zstd"
+
+	// DefaultCompressionMinSize is the smallest request body that is compressed
+	DefaultCompressionMinSize = 1024
+)
+
+type (
+	// Codec implements a content coding like gzip.
+	Codec interface {
+		NewWriter(w io.Writer) (io.WriteCloser, error)
+		NewReader(r io.Reader) (io.ReadCloser, error)
+	}
+
+	gzipCodec    struct{}
+	deflateCodec struct{}
+	zstdCodec    struct{}
+
+	// decodingTransport negotiates the content codings of responses and decodes them
+	decodingTransport struct {
+		InnerTransport http.RoundTripper
+		acceptEncoding string
+	}
+
+	// decodedBody closes both the decoder and the underlying body
+	decodedBody struct {
+		io.Reader
+		closers []io.Closer
+	}
+)
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[string]Codec{
+		EncodingGzip:    gzipCodec{},
+		EncodingDeflate: deflateCodec{},
+		EncodingZstd:    zstdCodec{},
+	}
+)
+
+// RegisterCodec makes a content coding available to WithCompression and WithAcceptEncoding,
+// e.g. br. An existing codec of the same name, including gzip, deflate and zstd, is replaced.
+func RegisterCodec(name string, codec Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[strings.ToLower(name)] = codec
+}
+
+func lookupCodec(name string) (Codec, error) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	if codec, ok := codecs[strings.ToLower(strings.TrimSpace(name))]; ok {
+		return codec, nil
+	}
+	return nil, fmt.Errorf("unsupported content encoding '%s', register a Codec for it with RegisterCodec", name)
+}
+
+// compress encodes data with the content coding encoding.
+func compress(encoding string, data []byte) ([]byte, error) {
+	codec, err := lookupCodec(encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	w, err := codec.NewWriter(&buf)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decode returns a reader for body, decoding the content codings of a Content-Encoding header in reverse order.
+func decode(contentEncoding string, body io.ReadCloser) (io.ReadCloser, error) {
+	decoded := &decodedBody{Reader: body, closers: []io.Closer{body}}
+
+	encodings := strings.Split(contentEncoding, ",")
+	for i := len(encodings) - 1; i >= 0; i-- {
+		encoding := strings.TrimSpace(encodings[i])
+		if encoding == "" || strings.EqualFold(encoding, "identity") {
+			continue
+		}
+		codec, err := lookupCodec(encoding)
+		if err != nil {
+			return nil, err
+		}
+		r, err := codec.NewReader(decoded.Reader)
+		if err != nil {
+			return nil, err
+		}
+		decoded.Reader = r
+		decoded.closers = append(decoded.closers, r)
+	}
+	return decoded, nil
+}
+
+// RoundTrip sets Accept-Encoding, unless the request has one, and decodes the response
+func (t *decodingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Accept-Encoding") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("Accept-Encoding", t.acceptEncoding)
+	}
+
+	resp, err := t.InnerTransport.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	contentEncoding := resp.Header.Get("Content-Encoding")
+	if contentEncoding == "" || req.Method == http.MethodHead || resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified {
+		return resp, nil
+	}
+
+	body, err := decode(contentEncoding, resp.Body)
+	if err != nil {
+		_ = resp.Body.Close()
+		return nil, err
+	}
+	resp.Body = body
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return resp, nil
+}
+
+func (b *decodedBody) Close() error {
+	var err error
+	for i := len(b.closers) - 1; i >= 0; i-- {
+		if cerr := b.closers[i].Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (gzipCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(w), nil
+}
+
+func (gzipCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+func (deflateCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return zlib.NewWriter(w), nil
+}
+
+func (deflateCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return zlib.NewReader(r)
+}
+
+func (zstdCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+}
+
+func (zstdCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	// a single goroutine per body, Close releases it
+	d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return d.IOReadCloser(), nil
+}
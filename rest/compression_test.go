@@ -0,0 +1,192 @@
+package rest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// reverseCodec stands in for a codec like br that is registered by the application
+type reverseCodec struct{}
+
+type reverseWriter struct {
+	w   io.Writer
+	buf bytes.Buffer
+}
+
+func (reverseCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return &reverseWriter{w: w}, nil
+}
+
+func (reverseCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(reverse(data))), nil
+}
+
+func (w *reverseWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+func (w *reverseWriter) Close() error {
+	_, err := w.w.Write(reverse(w.buf.Bytes()))
+	return err
+}
+
+func reverse(data []byte) []byte {
+	reversed := make([]byte, len(data))
+	for i, b := range data {
+		reversed[len(data)-1-i] = b
+	}
+	return reversed
+}
+
+func TestRequestCompression(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == EncodingGzip {
+			zr, err := gzip.NewReader(r.Body)
+			if !assert.NoError(t, err) {
+				return
+			}
+			body = zr
+		}
+		data, _ := io.ReadAll(body)
+		json.NewEncoder(w).Encode(map[string]any{"encoding": r.Header.Get("Content-Encoding"), "size": len(data)})
+	}))
+	defer srv.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL), WithCompression(EncodingGzip, 100))
+	assert.NoError(t, err)
+
+	large := map[string]string{"data": strings.Repeat("x", 200)}
+	resp := struct {
+		Encoding string
+		Size     int
+	}{}
+	_, err = cl.POST("/upload", large, &resp)
+	assert.NoError(t, err)
+	assert.Equal(t, EncodingGzip, resp.Encoding)
+	assert.Equal(t, 211, resp.Size)
+
+	// small bodies are sent as they are
+	_, err = cl.POST("/upload", map[string]string{"data": "x"}, &resp)
+	assert.NoError(t, err)
+	assert.Equal(t, "", resp.Encoding)
+
+	_, err = NewRestClient(context.TODO(), WithEndpoint(srv.URL), WithCompression("unknown", 0))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "RegisterCodec")
+	}
+	_, err = NewRestClient(context.TODO(), WithEndpoint(srv.URL), WithAcceptEncoding("unknown", EncodingGzip))
+	assert.Error(t, err)
+}
+
+func TestZstd(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, EncodingZstd, r.Header.Get("Content-Encoding"))
+		assert.Equal(t, EncodingZstd, r.Header.Get("Accept-Encoding"))
+		zr, err := zstd.NewReader(r.Body)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer zr.Close()
+		data, _ := io.ReadAll(zr)
+
+		w.Header().Set("Content-Encoding", EncodingZstd)
+		zw, _ := zstd.NewWriter(w)
+		json.NewEncoder(zw).Encode(map[string]any{"size": len(data)})
+		zw.Close()
+	}))
+	defer srv.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL), WithCompression(EncodingZstd, 100), WithAcceptEncoding(EncodingZstd))
+	assert.NoError(t, err)
+
+	resp := struct{ Size int }{}
+	_, err = cl.POST("/upload", map[string]string{"data": strings.Repeat("x", 200)}, &resp)
+	assert.NoError(t, err)
+	assert.Equal(t, 211, resp.Size)
+}
+
+func TestAcceptEncoding(t *testing.T) {
+	RegisterCodec("x-reverse", reverseCodec{})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "x-reverse, gzip", r.Header.Get("Accept-Encoding"))
+
+		body := []byte(`{"name":"compressed"}`)
+		if r.URL.Path == "/gzip" {
+			w.Header().Set("Content-Encoding", "gzip")
+			zw := gzip.NewWriter(w)
+			zw.Write(body)
+			zw.Close()
+			return
+		}
+		w.Header().Set("Content-Encoding", "x-reverse")
+		w.Write(reverse(body))
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	logger, level := log.Logger, zerolog.GlobalLevel()
+	log.Logger = zerolog.New(&buf)
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+	defer func() {
+		log.Logger = logger
+		zerolog.SetGlobalLevel(level)
+	}()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL), WithAcceptEncoding("x-reverse", EncodingGzip))
+	assert.NoError(t, err)
+
+	for _, path := range []string{"/gzip", "/reverse"} {
+		resp := map[string]string{}
+		meta, err := cl.Call(context.TODO(), NewRequest(http.MethodGet, path), &resp)
+		assert.NoError(t, err)
+		assert.Equal(t, "compressed", resp["name"])
+		assert.Empty(t, meta.Header.Get("Content-Encoding"))
+	}
+
+	// the logs show the decoded bodies
+	assert.Equal(t, 2, strings.Count(buf.String(), `"body":"{\"name\":\"compressed\"}"`))
+
+	_, err = NewRestClient(context.TODO(), WithEndpoint(srv.URL), WithAcceptEncoding("br"))
+	assert.Error(t, err)
+}
+
+func TestLogCompressedRequest(t *testing.T) {
+	var buf bytes.Buffer
+	logger, level := log.Logger, zerolog.GlobalLevel()
+	log.Logger = zerolog.New(&buf)
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+	defer func() {
+		log.Logger = logger
+		zerolog.SetGlobalLevel(level)
+	}()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL), WithCompression(EncodingDeflate, 1))
+	assert.NoError(t, err)
+
+	_, err = cl.POST("/", map[string]string{"name": "plain"}, nil)
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), `"body":"{\"name\":\"plain\"}"`)
+}
@@ -0,0 +1,345 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	SelectFailover     = "failover"      // the first healthy endpoint, in the configured order
+	SelectRoundRobin   = "round_robin"   // the healthy endpoints in turns
+	SelectLeastLatency = "least_latency" // the healthy endpoint with the lowest average latency
+
+	// DefaultEndpointCooldown is the time an endpoint is avoided after a failure
+	DefaultEndpointCooldown = 30 * time.Second
+)
+
+type (
+	// EndpointPool is a RoundTripper that sends requests addressed to one of its endpoints to the
+	// endpoint chosen by Selection. A connection error or 5xx marks an endpoint as unhealthy for
+	// Cooldown and fails over to the next one. Idempotent requests without a body are hedged:
+	// if no response arrived after HedgeDelay, the request is also sent to the next endpoint.
+	EndpointPool struct {
+		InnerTransport http.RoundTripper
+		Selection      string
+		HedgeDelay     time.Duration // 0 disables hedging
+		Cooldown       time.Duration
+
+		endpoints []*endpoint
+		next      atomic.Uint32
+		mu        sync.Mutex
+	}
+
+	// EndpointStatus reports the health of an endpoint.
+	EndpointStatus struct {
+		URL       string
+		Healthy   bool
+		Failures  int
+		Latency   time.Duration // moving average
+		DownUntil time.Time
+	}
+
+	endpoint struct {
+		url       string
+		failures  int
+		latency   time.Duration
+		downUntil time.Time
+	}
+
+	// cancelBody cancels the context of a hedged request once its response is consumed
+	cancelBody struct {
+		io.ReadCloser
+		cancel context.CancelFunc
+	}
+
+	hedgeResult struct {
+		idx  int
+		resp *http.Response
+		err  error
+	}
+)
+
+// NewEndpointPool wraps transport with a pool of endpoints, e.g. "https://eu.example.com/v1", "https://us.example.com/v1".
+func NewEndpointPool(transport http.RoundTripper, endpoints []string, selection string) *EndpointPool {
+	p := &EndpointPool{
+		InnerTransport: transport,
+		Selection:      selection,
+		Cooldown:       DefaultEndpointCooldown,
+	}
+	for _, e := range endpoints {
+		if e = strings.TrimSpace(e); e != "" {
+			p.endpoints = append(p.endpoints, &endpoint{url: strings.TrimSuffix(e, "/")})
+		}
+	}
+	return p
+}
+
+// Status returns the health of all endpoints, in the configured order.
+func (p *EndpointPool) Status() []EndpointStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	status := make([]EndpointStatus, len(p.endpoints))
+	for i, ep := range p.endpoints {
+		status[i] = EndpointStatus{
+			URL:       ep.url,
+			Healthy:   !now.Before(ep.downUntil),
+			Failures:  ep.failures,
+			Latency:   ep.latency,
+			DownUntil: ep.downUntil,
+		}
+	}
+	return status
+}
+
+// RoundTrip sends req to the pool's endpoints until one succeeds
+func (p *EndpointPool) RoundTrip(req *http.Request) (*http.Response, error) {
+	rest, ok := p.match(req.URL)
+	if !ok {
+		return p.InnerTransport.RoundTrip(req)
+	}
+
+	order := p.order()
+	if p.HedgeDelay > 0 && len(order) > 1 && (req.Method == http.MethodGet || req.Method == http.MethodHead) && (req.Body == nil || req.Body == http.NoBody) {
+		return p.hedge(req, rest, order)
+	}
+
+	var resp *http.Response
+	var err error
+	for i, ep := range order {
+		attempt, rerr := rewrite(req, ep.url+rest, i > 0)
+		if rerr != nil {
+			return nil, rerr
+		}
+
+		start := time.Now()
+		resp, err = p.InnerTransport.RoundTrip(attempt)
+		if !p.update(ep, resp, err, time.Since(start)) {
+			return resp, err
+		}
+		if i == len(order)-1 || !canFailover(req, err) {
+			break
+		}
+
+		log.Warn().Str("from", ep.url).Str("to", order[i+1].url).AnErr("error", err).Str("uid", requestID(req)).Msg("FAILOVER")
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+	}
+	return resp, err
+}
+
+// hedge sends req to the first endpoint and, while there is no response, to one more endpoint after every HedgeDelay.
+// The first successful response wins, otherwise the last failure is returned.
+func (p *EndpointPool) hedge(req *http.Request, rest string, order []*endpoint) (*http.Response, error) {
+	results := make(chan hedgeResult, len(order))
+	cancels := make([]context.CancelFunc, 0, len(order))
+	defer func() {
+		for _, cancel := range cancels {
+			if cancel != nil {
+				cancel()
+			}
+		}
+	}()
+
+	send := func(ep *endpoint) {
+		ctx, cancel := context.WithCancel(req.Context())
+		idx := len(cancels)
+		cancels = append(cancels, cancel)
+
+		attempt, err := rewrite(req.WithContext(ctx), ep.url+rest, false)
+		if err != nil {
+			results <- hedgeResult{idx: idx, err: err}
+			return
+		}
+		go func() {
+			start := time.Now()
+			resp, err := p.InnerTransport.RoundTrip(attempt)
+			if resp != nil {
+				resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+			}
+			p.update(ep, resp, err, time.Since(start))
+			results <- hedgeResult{idx: idx, resp: resp, err: err}
+		}()
+	}
+
+	send(order[0])
+	sent, pending := 1, 1
+	timer := time.NewTimer(p.HedgeDelay)
+	defer timer.Stop()
+
+	var last hedgeResult
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			if sent < len(order) {
+				log.Debug().Str("to", order[sent].url).Str("uid", requestID(req)).Msg("HEDGE")
+				send(order[sent])
+				sent++
+				pending++
+				timer.Reset(p.HedgeDelay)
+			}
+		case res := <-results:
+			pending--
+			if res.err == nil && res.resp.StatusCode < http.StatusInternalServerError {
+				cancels[res.idx] = nil // closing the body cancels the winner
+				go drain(results, pending)
+				return res.resp, nil
+			}
+
+			if last.resp != nil {
+				_ = last.resp.Body.Close()
+			}
+			last = res
+			if pending == 0 && sent < len(order) {
+				// failed before the hedge delay, fail over right away
+				send(order[sent])
+				sent++
+				pending++
+				timer.Reset(p.HedgeDelay)
+			}
+		}
+	}
+	return last.resp, last.err
+}
+
+// match returns the part of u following the endpoint it addresses.
+func (p *EndpointPool) match(u *url.URL) (string, bool) {
+	s := u.String()
+	for _, ep := range p.endpoints {
+		if rest, ok := strings.CutPrefix(s, ep.url); ok && (rest == "" || strings.ContainsAny(rest[:1], "/?#")) {
+			return rest, true
+		}
+	}
+	return "", false
+}
+
+// order returns the endpoints in the order they are tried, healthy ones first.
+func (p *EndpointPool) order() []*endpoint {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	healthy := make([]*endpoint, 0, len(p.endpoints))
+	var down []*endpoint
+	for _, ep := range p.endpoints {
+		if now.Before(ep.downUntil) {
+			down = append(down, ep)
+		} else {
+			healthy = append(healthy, ep)
+		}
+	}
+
+	switch p.Selection {
+	case SelectRoundRobin:
+		if n := len(healthy); n > 1 {
+			k := int(p.next.Add(1)-1) % n
+			rotated := make([]*endpoint, 0, len(p.endpoints))
+			healthy = append(append(rotated, healthy[k:]...), healthy[:k]...)
+		}
+	case SelectLeastLatency:
+		// insertion sort, pools are small. Unmeasured endpoints come first to get measured.
+		for i := 1; i < len(healthy); i++ {
+			for j := i; j > 0 && healthy[j].latency < healthy[j-1].latency; j-- {
+				healthy[j], healthy[j-1] = healthy[j-1], healthy[j]
+			}
+		}
+	}
+
+	// unhealthy endpoints are the last resort, the one recovering first leads
+	for i := 1; i < len(down); i++ {
+		for j := i; j > 0 && down[j].downUntil.Before(down[j-1].downUntil); j-- {
+			down[j], down[j-1] = down[j-1], down[j]
+		}
+	}
+	return append(healthy, down...)
+}
+
+// update records the outcome of a request to ep and reports whether it failed.
+func (p *EndpointPool) update(ep *endpoint, resp *http.Response, err error, d time.Duration) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err != nil && errors.Is(err, context.Canceled) {
+		return true // a hedged request that lost, or the caller gave up
+	}
+	if err != nil || resp.StatusCode >= http.StatusInternalServerError {
+		ep.failures++
+		cooldown := p.Cooldown
+		if cooldown <= 0 {
+			cooldown = DefaultEndpointCooldown
+		}
+		ep.downUntil = time.Now().Add(cooldown)
+		return true
+	}
+
+	ep.failures = 0
+	ep.downUntil = time.Time{}
+	if ep.latency == 0 {
+		ep.latency = d
+	} else {
+		ep.latency = (4*ep.latency + d) / 5
+	}
+	return false
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// rewrite returns a copy of req sent to target. A retry gets a fresh body.
+func rewrite(req *http.Request, target string, retry bool) (*http.Request, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	attempt := req.Clone(req.Context())
+	attempt.URL = u
+	attempt.Host = ""
+	if retry && req.Body != nil && req.Body != http.NoBody && req.GetBody != nil {
+		if attempt.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	return attempt, nil
+}
+
+// canFailover reports whether a failed request may be sent to another endpoint. Requests that
+// did not reach a server can always be sent again, others only if they are idempotent.
+func canFailover(req *http.Request, err error) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// drain closes the responses of hedged requests that lost.
+func drain(results chan hedgeResult, pending int) {
+	for ; pending > 0; pending-- {
+		if res := <-results; res.resp != nil {
+			_ = res.resp.Body.Close()
+		}
+	}
+}
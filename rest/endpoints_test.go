@@ -0,0 +1,139 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newEndpoint returns a server that replies with its name, after delay.
+func newEndpoint(name string, status int, delay time.Duration, calls *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"name":"%s","path":"%s"}`, name, r.URL.Path)
+	}))
+}
+
+func TestEndpointFailover(t *testing.T) {
+	var callsA, callsB, callsC atomic.Int32
+	down := newEndpoint("down", http.StatusOK, 0, &callsA)
+	down.Close()
+	failing := newEndpoint("failing", http.StatusInternalServerError, 0, &callsB)
+	defer failing.Close()
+	healthy := newEndpoint("healthy", http.StatusOK, 0, &callsC)
+	defer healthy.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoints(SelectFailover, down.URL+"/v1", failing.URL+"/v1", healthy.URL+"/v1"))
+	assert.NoError(t, err)
+	assert.Equal(t, down.URL+"/v1", cl.Settings.Endpoint)
+
+	resp := map[string]string{}
+	meta, err := cl.Call(context.TODO(), NewRequest(http.MethodGet, "/users"), &resp)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, meta.Attempts)
+	assert.Equal(t, "healthy", resp["name"])
+	assert.Equal(t, "/v1/users", resp["path"])
+	assert.Equal(t, int32(1), callsB.Load())
+
+	// unhealthy endpoints are avoided
+	_, err = cl.Call(context.TODO(), NewRequest(http.MethodGet, "/users"), &resp)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), callsB.Load())
+	assert.Equal(t, int32(2), callsC.Load())
+
+	// a POST is not sent twice once it reached a server
+	pool := NewEndpointPool(http.DefaultTransport, []string{failing.URL, healthy.URL}, SelectFailover)
+	req, _ := http.NewRequest(http.MethodPost, failing.URL+"/users", strings.NewReader("{}"))
+	r, err := pool.RoundTrip(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, r.StatusCode)
+
+	// but it is if the endpoint could not be reached
+	pool = NewEndpointPool(http.DefaultTransport, []string{down.URL, healthy.URL}, SelectFailover)
+	req, _ = http.NewRequest(http.MethodPost, down.URL+"/users", strings.NewReader("{}"))
+	r, err = pool.RoundTrip(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, r.StatusCode)
+
+	status := pool.Status()
+	assert.False(t, status[0].Healthy)
+	assert.Equal(t, 1, status[0].Failures)
+	assert.True(t, status[1].Healthy)
+
+	// requests elsewhere pass unchanged
+	req, _ = http.NewRequest(http.MethodGet, down.URL+"0/users", nil)
+	_, err = pool.RoundTrip(req)
+	assert.Error(t, err)
+	assert.Equal(t, 1, pool.Status()[0].Failures)
+}
+
+func TestEndpointSelection(t *testing.T) {
+	var callsA, callsB atomic.Int32
+	slow := newEndpoint("slow", http.StatusOK, 20*time.Millisecond, &callsA)
+	defer slow.Close()
+	fast := newEndpoint("fast", http.StatusOK, 0, &callsB)
+	defer fast.Close()
+
+	pool := NewEndpointPool(http.DefaultTransport, []string{slow.URL, fast.URL}, SelectRoundRobin)
+	for i := 0; i < 10; i++ {
+		req, _ := http.NewRequest(http.MethodGet, slow.URL+"/", nil)
+		resp, err := pool.RoundTrip(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+	}
+	assert.Equal(t, int32(5), callsA.Load())
+	assert.Equal(t, int32(5), callsB.Load())
+
+	callsA.Store(0)
+	callsB.Store(0)
+	pool = NewEndpointPool(http.DefaultTransport, []string{slow.URL, fast.URL}, SelectLeastLatency)
+	for i := 0; i < 10; i++ {
+		req, _ := http.NewRequest(http.MethodGet, slow.URL+"/", nil)
+		resp, err := pool.RoundTrip(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+	}
+	assert.Equal(t, int32(1), callsA.Load()) // measured once
+	assert.Equal(t, int32(9), callsB.Load())
+	assert.Less(t, pool.Status()[1].Latency, pool.Status()[0].Latency)
+}
+
+func TestHedging(t *testing.T) {
+	var callsA, callsB atomic.Int32
+	slow := newEndpoint("slow", http.StatusOK, time.Second, &callsA)
+	defer slow.Close()
+	fast := newEndpoint("fast", http.StatusOK, 0, &callsB)
+	defer fast.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoints(SelectFailover, slow.URL, fast.URL), WithHedging(20*time.Millisecond))
+	assert.NoError(t, err)
+
+	start := time.Now()
+	resp := map[string]string{}
+	_, err = cl.Call(context.TODO(), NewRequest(http.MethodGet, "/report"), &resp)
+	assert.NoError(t, err)
+	assert.Equal(t, "fast", resp["name"])
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Equal(t, int32(1), callsA.Load())
+	assert.Equal(t, int32(1), callsB.Load())
+
+	// writes are never hedged
+	ctx, cancel := context.WithTimeout(context.TODO(), 100*time.Millisecond)
+	defer cancel()
+	_, err = cl.Call(ctx, NewRequest(http.MethodPost, "/report"), nil)
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), callsB.Load())
+}
@@ -0,0 +1,66 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+)
+
+type (
+	// Error is returned by failed calls. It carries the request ID of the call
+	// in order to find it in the logs of both client and server.
+	Error struct {
+		StatusCode int
+		Message    string // the response body, if the API replied
+		RequestID  string // the ID the client sent and logged
+		Err        error  // the cause, if the API did not reply
+		// ServerRequestID is the X-Request-ID of the response, if the server assigned its own ID
+		ServerRequestID string
+	}
+)
+
+var (
+	ctxKeyRequestID  = &contextKey{"RequestID"}
+	ctxKeyForceTrace = &contextKey{"ForceTrace"}
+)
+
+func (e *Error) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return http.StatusText(e.StatusCode)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ContextWithRequestID returns a context that makes calls use id as their request ID
+// instead of a new one, e.g. to pass on the ID of an incoming request.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKeyRequestID, id)
+}
+
+// RequestIDFromContext returns the request ID set by ContextWithRequestID, or an empty string.
+func RequestIDFromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(ctxKeyRequestID).(string); ok {
+		return id
+	}
+	return ""
+}
+
+// ContextWithForceTrace returns a context that makes calls send value as X-Force-Trace,
+// unless the client has a value of its own, e.g. to pass on the marker of an incoming request.
+func ContextWithForceTrace(ctx context.Context, value string) context.Context {
+	return context.WithValue(ctx, ctxKeyForceTrace, value)
+}
+
+// ForceTraceFromContext returns the value set by ContextWithForceTrace, or an empty string.
+func ForceTraceFromContext(ctx context.Context) string {
+	if v, ok := ctx.Value(ctxKeyForceTrace).(string); ok {
+		return v
+	}
+	return ""
+}
@@ -0,0 +1,144 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestIDPropagation(t *testing.T) {
+	var buf bytes.Buffer
+	var calls atomic.Int32
+	seen := make(chan string, 3)
+
+	logger, level := log.Logger, zerolog.GlobalLevel()
+	log.Logger = zerolog.New(&buf)
+	zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	defer func() {
+		log.Logger = logger
+		zerolog.SetGlobalLevel(level)
+	}()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen <- r.Header.Get("X-Request-ID")
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("invalid"))
+	}))
+	defer srv.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL))
+	assert.NoError(t, err)
+
+	ctx := ContextWithRequestID(context.TODO(), "incoming-id")
+	assert.Equal(t, "incoming-id", RequestIDFromContext(ctx))
+
+	meta, err := cl.Call(ctx, NewRequest("GET", "/"), nil)
+	assert.Error(t, err)
+	assert.Equal(t, 3, meta.Attempts)
+	assert.Equal(t, "incoming-id", meta.RequestID)
+
+	// the same ID on every attempt
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, "incoming-id", <-seen)
+	}
+
+	var apiErr *Error
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	assert.Equal(t, "incoming-id", apiErr.RequestID)
+	assert.Equal(t, "invalid", err.Error())
+
+	out := buf.String()
+	assert.Equal(t, 3, strings.Count(out, `"message":"ATTEMPT"`))
+	assert.Contains(t, out, `"attempt":3`)
+	assert.Equal(t, strings.Count(out, `"uid":"incoming-id"`), strings.Count(out, `"uid":`))
+}
+
+func TestRequestIDGenerated(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NotEmpty(t, r.Header.Get("X-Request-ID"))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL))
+	assert.NoError(t, err)
+
+	meta, err := cl.Call(context.TODO(), NewRequest("GET", "/"), nil)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, meta.RequestID)
+}
+
+func TestErrorWrapsCause(t *testing.T) {
+	cl, err := NewRestClient(context.TODO(), WithEndpoint("http://127.0.0.1:1"), WithTransport(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, ErrCircuitOpen
+	})))
+	assert.NoError(t, err)
+
+	_, err = cl.GET("/", nil)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+
+	var apiErr *Error
+	assert.True(t, errors.As(err, &apiErr))
+	assert.NotEmpty(t, apiErr.RequestID)
+	assert.Equal(t, ErrCircuitOpen.Error(), err.Error())
+}
+
+func TestForceTracePropagation(t *testing.T) {
+	seen := make(chan string, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen <- r.Header.Get("X-Force-Trace")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL))
+	assert.NoError(t, err)
+	cl.Trace = ""
+
+	ctx := ContextWithForceTrace(context.TODO(), "incoming-trace")
+	assert.Equal(t, "incoming-trace", ForceTraceFromContext(ctx))
+	assert.Empty(t, ForceTraceFromContext(context.TODO()))
+
+	_, err = cl.Call(ctx, NewRequest("GET", "/"), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "incoming-trace", <-seen)
+
+	// the client's own value takes precedence
+	cl.Trace = "client-trace"
+	_, err = cl.Call(ctx, NewRequest("GET", "/"), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "client-trace", <-seen)
+}
+
+func TestServerRequestID(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-ID", "server-id")
+		w.WriteHeader(http.StatusConflict)
+	}))
+	defer srv.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL))
+	assert.NoError(t, err)
+
+	meta, err := cl.Call(ContextWithRequestID(context.TODO(), "client-id"), NewRequest("GET", "/"), nil)
+	var apiErr *Error
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "client-id", apiErr.RequestID)
+	assert.Equal(t, "server-id", apiErr.ServerRequestID)
+	assert.Equal(t, "client-id", meta.RequestID)
+	assert.Equal(t, "server-id", meta.ServerRequestID)
+}
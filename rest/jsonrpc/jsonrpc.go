@@ -0,0 +1,206 @@
+// Package jsonrpc implements a JSON-RPC 2.0 client on top of rest.RestClient, sharing its
+// settings, authentication, retries and logging.
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/txsvc/stdlib/v2"
+	"github.com/txsvc/stdlib/v2/rest"
+)
+
+const (
+	Version = "2.0"
+
+	// error codes defined by the specification
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+type (
+	// Client calls the JSON-RPC methods of the endpoint at path.
+	Client struct {
+		rest *rest.RestClient
+		path string
+		id   atomic.Int64
+	}
+
+	// Call is one call of a batch. Result receives the decoded result, Err the outcome.
+	// A notification expects no reply, neither a result nor an RPC error.
+	Call struct {
+		Method       string
+		Params       interface{}
+		Result       interface{}
+		Notification bool
+		Err          error
+
+		id int64
+	}
+
+	// Error is an RPC error object returned by the server.
+	Error struct {
+		Code    int             `json:"code"`
+		Message string          `json:"message"`
+		Data    json.RawMessage `json:"data,omitempty"`
+	}
+
+	request struct {
+		Version string      `json:"jsonrpc"`
+		Method  string      `json:"method"`
+		Params  interface{} `json:"params,omitempty"`
+		ID      *int64      `json:"id,omitempty"`
+	}
+
+	response struct {
+		Version string          `json:"jsonrpc"`
+		Result  json.RawMessage `json:"result"`
+		Error   *Error          `json:"error"`
+		ID      json.RawMessage `json:"id"`
+	}
+)
+
+var (
+	// errors defined by the specification, matched by code with errors.Is
+	ErrParse          = &Error{Code: CodeParseError, Message: "Parse error"}
+	ErrInvalidRequest = &Error{Code: CodeInvalidRequest, Message: "Invalid Request"}
+	ErrMethodNotFound = &Error{Code: CodeMethodNotFound, Message: "Method not found"}
+	ErrInvalidParams  = &Error{Code: CodeInvalidParams, Message: "Invalid params"}
+	ErrInternal       = &Error{Code: CodeInternalError, Message: "Internal error"}
+
+	// ErrMissingResponse indicates that the server did not reply to a call of a batch
+	ErrMissingResponse = errors.New("missing response")
+)
+
+// NewClient returns a client for the JSON-RPC endpoint at path, relative to the endpoint of c.
+func NewClient(c *rest.RestClient, path string) *Client {
+	return &Client{
+		rest: c,
+		path: path,
+	}
+}
+
+// Call calls method with params, by position (a slice) or by name (a struct or map), and decodes the result into result.
+// An RPC error is returned as *Error.
+func (c *Client) Call(ctx context.Context, method string, params, result interface{}) error {
+	call := &Call{Method: method, Params: params, Result: result}
+	if err := c.Batch(ctx, call); err != nil {
+		return err
+	}
+	return call.Err
+}
+
+// Invoke calls method with params and returns the result decoded as T.
+func Invoke[T any](ctx context.Context, c *Client, method string, params interface{}) (T, error) {
+	var result T
+	err := c.Call(ctx, method, params, &result)
+	return result, err
+}
+
+// Notify calls method with params without waiting for a result.
+func (c *Client) Notify(ctx context.Context, method string, params interface{}) error {
+	return c.Batch(ctx, &Call{Method: method, Params: params, Notification: true})
+}
+
+// Batch sends calls in one request. The outcome of each call is set in its Err, the returned
+// error reports a failure of the request as a whole.
+func (c *Client) Batch(ctx context.Context, calls ...*Call) error {
+	if len(calls) == 0 {
+		return nil
+	}
+
+	reqs := make([]request, len(calls))
+	pending := make(map[string]*Call, len(calls))
+	for i, call := range calls {
+		reqs[i] = request{Version: Version, Method: call.Method, Params: call.Params}
+		if !call.Notification {
+			call.id = c.id.Add(1)
+			reqs[i].ID = &call.id
+			pending[strconv.FormatInt(call.id, 10)] = call
+		}
+	}
+
+	// a single call is sent as an object, not as a batch of one
+	var body interface{} = reqs
+	if len(reqs) == 1 {
+		body = reqs[0]
+	}
+
+	if len(pending) == 0 {
+		_, err := c.rest.Call(ctx, rest.NewRequest(http.MethodPost, c.path).SetBody(body), nil)
+		return err
+	}
+
+	var raw json.RawMessage
+	if _, err := c.rest.Call(ctx, rest.NewRequest(http.MethodPost, c.path).SetBody(body), &raw); err != nil {
+		// some servers reply to a failed call with an error status and the error object
+		var apiErr *rest.Error
+		if errors.As(err, &apiErr) && apiErr.Message != "" {
+			var resp response
+			if json.Unmarshal([]byte(apiErr.Message), &resp) == nil && resp.Error != nil {
+				return resp.Error
+			}
+		}
+		return err
+	}
+
+	var responses []response
+	raw = bytes.TrimSpace(raw)
+	if len(raw) > 0 && raw[0] == '[' {
+		if err := json.Unmarshal(raw, &responses); err != nil {
+			return err
+		}
+	} else {
+		var resp response
+		if err := json.Unmarshal(raw, &resp); err != nil {
+			return err
+		}
+		responses = append(responses, resp)
+	}
+
+	for _, resp := range responses {
+		call, ok := pending[string(bytes.Trim(resp.ID, `"`))]
+		if !ok {
+			// an error without id, e.g. the batch could not be parsed
+			if resp.Error != nil && (len(resp.ID) == 0 || string(resp.ID) == "null") {
+				return resp.Error
+			}
+			continue
+		}
+		delete(pending, string(bytes.Trim(resp.ID, `"`)))
+
+		if resp.Error != nil {
+			call.Err = resp.Error
+		} else if call.Result != nil && len(resp.Result) > 0 {
+			call.Err = stdlib.Unmarshal(resp.Result, call.Result)
+		}
+	}
+	for _, call := range pending {
+		call.Err = fmt.Errorf("%w: %s (id %d)", ErrMissingResponse, call.Method, call.id)
+	}
+	return nil
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("jsonrpc: %s (%d)", e.Message, e.Code)
+}
+
+// Is matches RPC errors by code, e.g. errors.Is(err, jsonrpc.ErrMethodNotFound).
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// DecodeData unmarshals the additional information of the error into v.
+func (e *Error) DecodeData(v interface{}) error {
+	return stdlib.Unmarshal(e.Data, v)
+}
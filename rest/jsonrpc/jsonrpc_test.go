@@ -0,0 +1,160 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/txsvc/stdlib/v2/rest"
+)
+
+type rpcRequest struct {
+	Version string           `json:"jsonrpc"`
+	Method  string           `json:"method"`
+	Params  json.RawMessage  `json:"params"`
+	ID      *json.RawMessage `json:"id"`
+}
+
+// serve answers a single request: "add" sums its params, "fail" returns an error with data,
+// "log" is a notification.
+func serve(req rpcRequest, notified *[]string) map[string]interface{} {
+	if req.ID == nil {
+		*notified = append(*notified, req.Method)
+		return nil
+	}
+	resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+	switch req.Method {
+	case "add":
+		var params []int
+		json.Unmarshal(req.Params, &params)
+		sum := 0
+		for _, p := range params {
+			sum += p
+		}
+		resp["result"] = sum
+	case "fail":
+		resp["error"] = map[string]interface{}{"code": -32000, "message": "failed", "data": map[string]string{"reason": "broken"}}
+	default:
+		resp["error"] = map[string]interface{}{"code": CodeMethodNotFound, "message": "Method not found"}
+	}
+	return resp
+}
+
+func newServer(t *testing.T, notified *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		body, _ := io.ReadAll(r.Body)
+
+		if body[0] != '[' {
+			var req rpcRequest
+			if err := json.Unmarshal(body, &req); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error"},"id":null}`))
+				return
+			}
+			if resp := serve(req, notified); resp != nil {
+				json.NewEncoder(w).Encode(resp)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		var reqs []rpcRequest
+		json.Unmarshal(body, &reqs)
+		var resps []map[string]interface{}
+		for i := len(reqs) - 1; i >= 0; i-- { // in any order
+			if resp := serve(reqs[i], notified); resp != nil {
+				resps = append(resps, resp)
+			}
+		}
+		if len(resps) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		json.NewEncoder(w).Encode(resps)
+	}))
+}
+
+func TestCall(t *testing.T) {
+	var notified []string
+	srv := newServer(t, &notified)
+	defer srv.Close()
+
+	cl, err := rest.NewRestClient(context.TODO(), rest.WithEndpoint(srv.URL), rest.WithToken("id", "token"))
+	assert.NoError(t, err)
+	rpc := NewClient(cl, "/rpc")
+
+	var sum int
+	assert.NoError(t, rpc.Call(context.TODO(), "add", []int{1, 2, 3}, &sum))
+	assert.Equal(t, 6, sum)
+
+	sum, err = Invoke[int](context.TODO(), rpc, "add", []int{4, 5})
+	assert.NoError(t, err)
+	assert.Equal(t, 9, sum)
+
+	err = rpc.Call(context.TODO(), "fail", nil, nil)
+	var rpcErr *Error
+	assert.True(t, errors.As(err, &rpcErr))
+	assert.Equal(t, -32000, rpcErr.Code)
+	data := map[string]string{}
+	assert.NoError(t, rpcErr.DecodeData(&data))
+	assert.Equal(t, "broken", data["reason"])
+
+	err = rpc.Call(context.TODO(), "unknown", nil, nil)
+	assert.ErrorIs(t, err, ErrMethodNotFound)
+	assert.False(t, errors.Is(err, ErrInvalidParams))
+
+	assert.NoError(t, rpc.Notify(context.TODO(), "log", map[string]string{"msg": "hello"}))
+	assert.Equal(t, []string{"log"}, notified)
+}
+
+func TestBatch(t *testing.T) {
+	var notified []string
+	srv := newServer(t, &notified)
+	defer srv.Close()
+
+	cl, err := rest.NewRestClient(context.TODO(), rest.WithEndpoint(srv.URL), rest.WithToken("id", "token"))
+	assert.NoError(t, err)
+	rpc := NewClient(cl, "/rpc")
+
+	var a, b int
+	calls := []*Call{
+		{Method: "add", Params: []int{1, 1}, Result: &a},
+		{Method: "log", Notification: true},
+		{Method: "fail"},
+		{Method: "add", Params: []int{2, 2}, Result: &b},
+	}
+	assert.NoError(t, rpc.Batch(context.TODO(), calls...))
+	assert.NoError(t, calls[0].Err)
+	assert.Equal(t, 2, a)
+	assert.NoError(t, calls[1].Err)
+	assert.Error(t, calls[2].Err)
+	assert.NoError(t, calls[3].Err)
+	assert.Equal(t, 4, b)
+	assert.Equal(t, []string{"log"}, notified)
+
+	// notifications only
+	assert.NoError(t, rpc.Batch(context.TODO(), &Call{Method: "log", Notification: true}, &Call{Method: "log", Notification: true}))
+	assert.Len(t, notified, 3)
+}
+
+func TestErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error"},"id":null}`))
+	}))
+	defer srv.Close()
+
+	cl, err := rest.NewRestClient(context.TODO(), rest.WithEndpoint(srv.URL))
+	assert.NoError(t, err)
+
+	err = NewClient(cl, "/rpc").Call(context.TODO(), "add", []int{1}, nil)
+	assert.ErrorIs(t, err, ErrParse)
+}
@@ -0,0 +1,216 @@
+package rest
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// Metrics of a logical call, including all of its retries
+	MetricRequests        = "rest_client_requests_total"
+	MetricRequestDuration = "rest_client_request_duration_seconds"
+
+	// Metrics of every single attempt
+	MetricAttempts        = "rest_client_attempts_total"
+	MetricAttemptDuration = "rest_client_attempt_duration_seconds"
+	MetricRetries         = "rest_client_retries_total"
+
+	metricTypeCounter   = "counter"
+	metricTypeHistogram = "histogram"
+)
+
+type (
+	// Labels qualify a measurement, e.g. method, route, status and attempt.
+	Labels map[string]string
+
+	// Metrics receives the measurements of a RestClient.
+	Metrics interface {
+		// IncCounter increments the counter name by one.
+		IncCounter(name string, labels Labels)
+		// Observe records value in the histogram name.
+		Observe(name string, value float64, labels Labels)
+	}
+
+	// PrometheusMetrics collects measurements in memory and exposes them in the
+	// Prometheus text exposition format, e.g. on a /metrics endpoint.
+	PrometheusMetrics struct {
+		Buckets []float64 // upper bounds of the buckets, copied into each histogram when it is created
+
+		mu     sync.Mutex
+		types  map[string]string
+		series map[string]map[string]*series // name -> rendered labels -> series
+	}
+
+	series struct {
+		value   float64 // counter value or histogram sum
+		count   uint64
+		bounds  []float64
+		buckets []uint64
+	}
+)
+
+var (
+	// DefaultBuckets are the default upper bounds of histogram buckets, in seconds
+	DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+)
+
+// NewPrometheusMetrics returns an empty collector using DefaultBuckets.
+func NewPrometheusMetrics() *PrometheusMetrics {
+	return &PrometheusMetrics{
+		Buckets: DefaultBuckets,
+		types:   make(map[string]string),
+		series:  make(map[string]map[string]*series),
+	}
+}
+
+func (m *PrometheusMetrics) IncCounter(name string, labels Labels) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.get(name, metricTypeCounter, labels).value++
+}
+
+func (m *PrometheusMetrics) Observe(name string, value float64, labels Labels) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := m.get(name, metricTypeHistogram, labels)
+	if s.buckets == nil {
+		s.bounds = slices.Clone(m.Buckets)
+		s.buckets = make([]uint64, len(s.bounds))
+	}
+	s.value += value
+	s.count++
+	for i, le := range s.bounds {
+		if value <= le {
+			s.buckets[i]++
+		}
+	}
+}
+
+// WriteTo writes all metrics in the Prometheus text exposition format.
+func (m *PrometheusMetrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var sb strings.Builder
+
+	names := make([]string, 0, len(m.series))
+	for name := range m.series {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(&sb, "# TYPE %s %s\n", name, m.types[name])
+
+		keys := make([]string, 0, len(m.series[name]))
+		for k := range m.series[name] {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, labels := range keys {
+			s := m.series[name][labels]
+			if m.types[name] == metricTypeCounter {
+				fmt.Fprintf(&sb, "%s%s %s\n", name, wrapLabels(labels), formatFloat(s.value))
+				continue
+			}
+			for i, le := range s.bounds {
+				fmt.Fprintf(&sb, "%s_bucket%s %d\n", name, wrapLabels(joinLabels(labels, `le="`+formatFloat(le)+`"`)), s.buckets[i])
+			}
+			fmt.Fprintf(&sb, "%s_bucket%s %d\n", name, wrapLabels(joinLabels(labels, `le="+Inf"`)), s.count)
+			fmt.Fprintf(&sb, "%s_sum%s %s\n", name, wrapLabels(labels), formatFloat(s.value))
+			fmt.Fprintf(&sb, "%s_count%s %d\n", name, wrapLabels(labels), s.count)
+		}
+	}
+
+	n, err := io.WriteString(w, sb.String())
+	return int64(n), err
+}
+
+// ServeHTTP exposes the metrics to a Prometheus scraper.
+func (m *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = m.WriteTo(w)
+}
+
+func (m *PrometheusMetrics) get(name, typ string, labels Labels) *series {
+	if _, ok := m.series[name]; !ok {
+		m.series[name] = make(map[string]*series)
+		m.types[name] = typ
+	}
+
+	key := renderLabels(labels)
+	s, ok := m.series[name][key]
+	if !ok {
+		s = &series{}
+		m.series[name][key] = s
+	}
+	return s
+}
+
+// renderLabels returns the labels as sorted, escaped list without braces, e.g. `a="1",b="2"`.
+func renderLabels(labels Labels) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + `="` + escapeLabel(labels[k]) + `"`
+	}
+	return strings.Join(parts, ",")
+}
+
+func joinLabels(labels, label string) string {
+	if labels == "" {
+		return label
+	}
+	return labels + "," + label
+}
+
+func wrapLabels(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+func escapeLabel(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `"`, `\"`)
+	return strings.ReplaceAll(v, "\n", `\n`)
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// statusClass groups status codes into 2xx, 4xx etc. or "error" if there is no response.
+func statusClass(resp *http.Response, err error) string {
+	if err != nil || resp == nil {
+		return "error"
+	}
+	return strconv.Itoa(resp.StatusCode/100) + "xx"
+}
+
+// routeOf returns the path template of the call req belongs to.
+func routeOf(req *http.Request) string {
+	if state := callStateFromContext(req.Context()); state != nil {
+		return state.route
+	}
+	return ""
+}
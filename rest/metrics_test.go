@@ -0,0 +1,109 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrometheusMetrics(t *testing.T) {
+	m := NewPrometheusMetrics()
+	m.Buckets = []float64{0.1, 1}
+
+	m.IncCounter("requests_total", Labels{"method": "GET", "route": `/a"b`})
+	m.IncCounter("requests_total", Labels{"method": "GET", "route": `/a"b`})
+	m.Observe("duration_seconds", 0.05, Labels{"method": "GET"})
+	m.Observe("duration_seconds", 0.5, Labels{"method": "GET"})
+
+	var sb strings.Builder
+	_, err := m.WriteTo(&sb)
+	assert.NoError(t, err)
+
+	expected := `# TYPE duration_seconds histogram
+duration_seconds_bucket{method="GET",le="0.1"} 1
+duration_seconds_bucket{method="GET",le="1"} 2
+duration_seconds_bucket{method="GET",le="+Inf"} 2
+duration_seconds_sum{method="GET"} 0.55
+duration_seconds_count{method="GET"} 2
+# TYPE requests_total counter
+requests_total{method="GET",route="/a\"b"} 2
+`
+	assert.Equal(t, expected, sb.String())
+
+	// changing the buckets later does not affect existing histograms
+	m.Buckets = []float64{0.1, 1, 10}
+	m.Observe("duration_seconds", 5, Labels{"method": "GET"})
+	m.Observe("duration_seconds", 5, Labels{"method": "POST"})
+
+	sb.Reset()
+	_, err = m.WriteTo(&sb)
+	assert.NoError(t, err)
+	assert.Contains(t, sb.String(), `duration_seconds_bucket{method="GET",le="+Inf"} 3`)
+	assert.NotContains(t, sb.String(), `duration_seconds_bucket{method="GET",le="10"}`)
+	assert.Contains(t, sb.String(), `duration_seconds_bucket{method="POST",le="10"} 1`)
+}
+
+func TestClientMetrics(t *testing.T) {
+	var calls atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	m := NewPrometheusMetrics()
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL), WithMetrics(m))
+	assert.NoError(t, err)
+
+	_, err = cl.Do(context.TODO(), NewRequest("GET", "/users/{id}").SetParam("id", "1"), nil)
+	assert.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	out := rec.Body.String()
+
+	assert.Contains(t, out, `rest_client_requests_total{method="GET",route="/users/{id}",status="2xx"} 1`)
+	assert.Contains(t, out, `rest_client_attempts_total{attempt="1",method="GET",route="/users/{id}",status="5xx"} 1`)
+	assert.Contains(t, out, `rest_client_attempts_total{attempt="2",method="GET",route="/users/{id}",status="2xx"} 1`)
+	assert.Contains(t, out, `rest_client_retries_total{method="GET",route="/users/{id}"} 1`)
+	assert.Contains(t, out, `rest_client_request_duration_seconds_count{method="GET",route="/users/{id}",status="2xx"} 1`)
+	assert.Contains(t, out, `rest_client_attempt_duration_seconds_count{method="GET",route="/users/{id}",status="5xx"} 1`)
+}
+
+func TestMetricsRoute(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	m := NewPrometheusMetrics()
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL), WithMetrics(m))
+	assert.NoError(t, err)
+
+	for _, r := range []*Request{
+		NewRequest("GET", "/users/1").SetRoute("/users/{id}"),
+		NewRequest("GET", "/users/2").SetRoute("/users/{id}"),
+		NewRequest("GET", "/search?q=a"),
+		NewRequest("GET", srv.URL+"/search?q=b"),
+	} {
+		_, err = cl.Do(context.TODO(), r, nil)
+		assert.NoError(t, err)
+	}
+
+	var sb strings.Builder
+	_, _ = m.WriteTo(&sb)
+	out := sb.String()
+	assert.Contains(t, out, `rest_client_requests_total{method="GET",route="/users/{id}",status="2xx"} 2`)
+	assert.Contains(t, out, `rest_client_requests_total{method="GET",route="/search",status="2xx"} 2`)
+	assert.NotContains(t, out, "q=")
+	assert.NotContains(t, out, "/users/1")
+}
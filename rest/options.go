@@ -1,6 +1,49 @@
 package rest
 
-import "github.com/txsvc/stdlib/v2/settings"
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/txsvc/stdlib/v2/settings"
+)
+
+// Keys of the client settings kept in DialSettings.Options
+const (
+	OptionRateLimit      = "rate_limit"      // "rate,burst", per route as "rate_limit:[METHOD ]route"
+	OptionCircuitBreaker = "circuit_breaker" // "ratio,window,cooldown[,min requests]"
+	OptionSigning        = "signing"         // SigningHMAC or SigningHTTPMessage
+	OptionTLSCertFile    = "tls_cert_file"   // client certificate, PEM
+	OptionTLSKeyFile     = "tls_key_file"    // key of the client certificate, PEM
+	OptionTLSRootCAs     = "tls_root_cas"    // CA certificates to verify the server, PEM
+	OptionTLSMinVersion  = "tls_min_version" // "1.2" or "1.3"
+	OptionTLSPins        = "tls_pins"        // SHA-256 of accepted public keys, comma-separated
+
+	OptionTimeout               = "timeout"                 // overall timeout of a call, including retries
+	OptionAttemptTimeout        = "attempt_timeout"         // timeout of a single attempt
+	OptionDialTimeout           = "dial_timeout"            // timeout to establish a connection
+	OptionTLSHandshakeTimeout   = "tls_handshake_timeout"   // timeout of the TLS handshake
+	OptionResponseHeaderTimeout = "response_header_timeout" // timeout waiting for the response headers
+	OptionMaxIdleConnsPerHost   = "max_idle_conns_per_host" // size of the connection pool per host
+	OptionHTTP2                 = "http2"                   // "true" or "false"
+	OptionProxy                 = "proxy"                   // proxy URL, an empty value disables any proxy
+	OptionNoProxy               = "no_proxy"                // hosts not to proxy, comma-separated as in NO_PROXY
+
+	OptionEndpoints         = "endpoints"          // alternative endpoints, comma-separated
+	OptionEndpointSelection = "endpoint_selection" // SelectFailover, SelectRoundRobin or SelectLeastLatency
+	OptionHedgeDelay        = "hedge_delay"        // latency after which a GET is also sent to the next endpoint
+
+	OptionCompression    = "compression"     // content coding of request bodies and the minimum size, "gzip,1024"
+	OptionAcceptEncoding = "accept_encoding" // content codings accepted for responses, comma-separated by preference
+)
+
+// clientOption is implemented by options that configure the RestClient itself,
+// e.g. with objects that can't be kept in DialSettings.
+type clientOption interface {
+	applyClient(c *RestClient)
+}
 
 // WithEndpoint returns a ClientOption that overrides the default endpoint to be used for a service.
 func WithEndpoint(url string) settings.Option {
@@ -13,6 +56,22 @@ func (w withEndpoint) Apply(ds *settings.DialSettings) {
 	ds.Endpoint = string(w)
 }
 
+// WithTransport returns a ClientOption that replaces http.DefaultTransport as the transport
+// below the client's retry and logging layers, e.g. to record or fake responses in tests.
+func WithTransport(transport http.RoundTripper) settings.Option {
+	return withTransport{transport}
+}
+
+type withTransport struct {
+	transport http.RoundTripper
+}
+
+func (w withTransport) Apply(ds *settings.DialSettings) {}
+
+func (w withTransport) applyClient(c *RestClient) {
+	c.transport = w.transport
+}
+
 // WithCredentials returns a ClientOption that overrides the default credentials used for a service.
 func WithCredentials(clientid, secret string) settings.Option {
 	return withCredentials{
@@ -54,3 +113,344 @@ func (w withToken) Apply(ds *settings.DialSettings) {
 	ds.Credentials.ClientID = w.clientID
 	ds.Credentials.Token = w.token
 }
+
+// WithRateLimit returns a ClientOption that limits the client to rate requests/sec with bursts of up to burst requests.
+func WithRateLimit(rate float64, burst int) settings.Option {
+	return withRateLimit{
+		key:   OptionRateLimit,
+		rate:  rate,
+		burst: burst,
+	}
+}
+
+// WithRouteRateLimit returns a ClientOption that limits requests to a route, e.g. "/users/{id}" or "POST /users",
+// independently of the client's limit. The route is matched against the path template of the request.
+func WithRouteRateLimit(route string, rate float64, burst int) settings.Option {
+	return withRateLimit{
+		key:   OptionRateLimit + ":" + route,
+		rate:  rate,
+		burst: burst,
+	}
+}
+
+type withRateLimit struct {
+	key   string
+	rate  float64
+	burst int
+}
+
+func (w withRateLimit) Apply(ds *settings.DialSettings) {
+	ds.SetOption(w.key, fmt.Sprintf("%g,%d", w.rate, w.burst))
+}
+
+// WithCircuitBreaker returns a ClientOption that stops calling the backend for cooldown once
+// the ratio of failed requests within window reaches ratio.
+// The ratio must be greater than 0 and at most 1, window and cooldown positive, NewRestClient fails otherwise.
+func WithCircuitBreaker(ratio float64, window, cooldown time.Duration) settings.Option {
+	return withCircuitBreaker{
+		ratio:    ratio,
+		window:   window,
+		cooldown: cooldown,
+	}
+}
+
+type withCircuitBreaker struct {
+	ratio    float64
+	window   time.Duration
+	cooldown time.Duration
+}
+
+func (w withCircuitBreaker) Apply(ds *settings.DialSettings) {
+	ds.SetOption(OptionCircuitBreaker, fmt.Sprintf("%g,%s,%s", w.ratio, w.window, w.cooldown))
+}
+
+// WithCache returns a ClientOption that caches GET responses in cache and revalidates them using ETag/Last-Modified.
+func WithCache(cache Cache) settings.Option {
+	return withCache{cache}
+}
+
+type withCache struct {
+	cache Cache
+}
+
+func (w withCache) Apply(ds *settings.DialSettings) {}
+
+func (w withCache) applyClient(c *RestClient) {
+	c.cache = w.cache
+}
+
+// WithRedaction returns a ClientOption that replaces the default redaction of headers, JSON fields
+// and query parameters in the request and response logs. Start from DefaultRedactor() to extend it.
+func WithRedaction(redactor *Redactor) settings.Option {
+	return withRedaction{redactor}
+}
+
+type withRedaction struct {
+	redactor *Redactor
+}
+
+func (w withRedaction) Apply(ds *settings.DialSettings) {}
+
+func (w withRedaction) applyClient(c *RestClient) {
+	c.redactor = w.redactor
+}
+
+// WithMetrics returns a ClientOption that reports request and attempt counts and latencies to metrics.
+func WithMetrics(metrics Metrics) settings.Option {
+	return withMetrics{metrics}
+}
+
+type withMetrics struct {
+	metrics Metrics
+}
+
+func (w withMetrics) Apply(ds *settings.DialSettings) {}
+
+func (w withMetrics) applyClient(c *RestClient) {
+	c.metrics = w.metrics
+}
+
+// WithTracer returns a ClientOption that creates spans for every call and each of its attempts.
+func WithTracer(tracer Tracer) settings.Option {
+	return withTracer{tracer}
+}
+
+type withTracer struct {
+	tracer Tracer
+}
+
+func (w withTracer) Apply(ds *settings.DialSettings) {}
+
+func (w withTracer) applyClient(c *RestClient) {
+	c.tracer = w.tracer
+}
+
+// WithSigning returns a ClientOption that signs requests instead of sending the credentials.
+// The client ID is used as key id and the client secret as key, see SigningHMAC and SigningHTTPMessage.
+func WithSigning(scheme string) settings.Option {
+	return withSigning(scheme)
+}
+
+type withSigning string
+
+func (w withSigning) Apply(ds *settings.DialSettings) {
+	ds.SetOption(OptionSigning, string(w))
+}
+
+// WithSigner returns a ClientOption that signs requests with a custom signer instead of sending the credentials.
+func WithSigner(signer Signer) settings.Option {
+	return withSigner{signer}
+}
+
+type withSigner struct {
+	signer Signer
+}
+
+func (w withSigner) Apply(ds *settings.DialSettings) {}
+
+func (w withSigner) applyClient(c *RestClient) {
+	c.signer = w.signer
+}
+
+// WithClientCertificate returns a ClientOption that authenticates the client with a certificate (mutual TLS).
+func WithClientCertificate(certFile, keyFile string) settings.Option {
+	return withClientCertificate{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+}
+
+type withClientCertificate struct {
+	certFile string
+	keyFile  string
+}
+
+func (w withClientCertificate) Apply(ds *settings.DialSettings) {
+	ds.SetOption(OptionTLSCertFile, w.certFile)
+	ds.SetOption(OptionTLSKeyFile, w.keyFile)
+}
+
+// WithRootCAs returns a ClientOption that verifies the server with the CA certificates in pemFile
+// instead of the system's CAs, e.g. for a private CA.
+func WithRootCAs(pemFile string) settings.Option {
+	return withRootCAs(pemFile)
+}
+
+type withRootCAs string
+
+func (w withRootCAs) Apply(ds *settings.DialSettings) {
+	ds.SetOption(OptionTLSRootCAs, string(w))
+}
+
+// WithTLSMinVersion returns a ClientOption that sets the minimum TLS version, e.g. tls.VersionTLS13.
+func WithTLSMinVersion(version uint16) settings.Option {
+	return withTLSMinVersion(version)
+}
+
+type withTLSMinVersion uint16
+
+func (w withTLSMinVersion) Apply(ds *settings.DialSettings) {
+	for k, v := range tlsVersions {
+		if v == uint16(w) {
+			ds.SetOption(OptionTLSMinVersion, k)
+			return
+		}
+	}
+	// an unknown version is kept, e.g. as "0x0000", and rejected by NewRestClient
+	ds.SetOption(OptionTLSMinVersion, fmt.Sprintf("0x%04x", uint16(w)))
+}
+
+// WithCertificatePinning returns a ClientOption that only accepts servers presenting a certificate whose
+// public key (SubjectPublicKeyInfo) has one of the given SHA-256 digests, hex or base64 encoded.
+func WithCertificatePinning(sha256 ...string) settings.Option {
+	return withCertificatePinning(sha256)
+}
+
+type withCertificatePinning []string
+
+func (w withCertificatePinning) Apply(ds *settings.DialSettings) {
+	ds.SetOption(OptionTLSPins, strings.Join(w, ","))
+}
+
+// WithTimeout returns a ClientOption that limits the duration of a call, including all retries.
+func WithTimeout(timeout time.Duration) settings.Option {
+	return withDuration{OptionTimeout, timeout}
+}
+
+// WithAttemptTimeout returns a ClientOption that limits the duration of every single attempt of a call.
+func WithAttemptTimeout(timeout time.Duration) settings.Option {
+	return withDuration{OptionAttemptTimeout, timeout}
+}
+
+// WithDialTimeout returns a ClientOption that limits the time to establish a connection.
+func WithDialTimeout(timeout time.Duration) settings.Option {
+	return withDuration{OptionDialTimeout, timeout}
+}
+
+// WithTLSHandshakeTimeout returns a ClientOption that limits the time of the TLS handshake.
+func WithTLSHandshakeTimeout(timeout time.Duration) settings.Option {
+	return withDuration{OptionTLSHandshakeTimeout, timeout}
+}
+
+// WithResponseHeaderTimeout returns a ClientOption that limits the time to wait for the response headers
+// once the request was written.
+func WithResponseHeaderTimeout(timeout time.Duration) settings.Option {
+	return withDuration{OptionResponseHeaderTimeout, timeout}
+}
+
+type withDuration struct {
+	key string
+	d   time.Duration
+}
+
+func (w withDuration) Apply(ds *settings.DialSettings) {
+	ds.SetOption(w.key, w.d.String())
+}
+
+// WithMaxIdleConnsPerHost returns a ClientOption that sets the number of idle connections kept per host.
+func WithMaxIdleConnsPerHost(n int) settings.Option {
+	return withMaxIdleConnsPerHost(n)
+}
+
+type withMaxIdleConnsPerHost int
+
+func (w withMaxIdleConnsPerHost) Apply(ds *settings.DialSettings) {
+	ds.SetOption(OptionMaxIdleConnsPerHost, strconv.Itoa(int(w)))
+}
+
+// WithHTTP2 returns a ClientOption that enables or disables HTTP/2.
+func WithHTTP2(enabled bool) settings.Option {
+	return withHTTP2(enabled)
+}
+
+type withHTTP2 bool
+
+func (w withHTTP2) Apply(ds *settings.DialSettings) {
+	ds.SetOption(OptionHTTP2, strconv.FormatBool(bool(w)))
+}
+
+// WithProxy returns a ClientOption that sends requests through proxyURL, except for hosts matching noProxy
+// (e.g. "localhost", ".example.com", "10.0.0.0/8"). HTTP_PROXY etc. are ignored, an empty proxyURL disables proxies.
+func WithProxy(proxyURL string, noProxy ...string) settings.Option {
+	return withProxy{
+		proxyURL: proxyURL,
+		noProxy:  noProxy,
+	}
+}
+
+type withProxy struct {
+	proxyURL string
+	noProxy  []string
+}
+
+func (w withProxy) Apply(ds *settings.DialSettings) {
+	ds.SetOption(OptionProxy, w.proxyURL)
+	if len(w.noProxy) > 0 {
+		ds.SetOption(OptionNoProxy, strings.Join(w.noProxy, ","))
+	}
+}
+
+// WithEndpoints returns a ClientOption that spreads requests over several endpoints, e.g. a primary
+// and a secondary. The first one becomes the client's endpoint, see EndpointPool.
+func WithEndpoints(selection string, endpoints ...string) settings.Option {
+	return withEndpoints{
+		selection: selection,
+		endpoints: endpoints,
+	}
+}
+
+type withEndpoints struct {
+	selection string
+	endpoints []string
+}
+
+func (w withEndpoints) Apply(ds *settings.DialSettings) {
+	if len(w.endpoints) == 0 {
+		return
+	}
+	ds.Endpoint = w.endpoints[0]
+	ds.SetOption(OptionEndpoints, strings.Join(w.endpoints, ","))
+	if w.selection != "" {
+		ds.SetOption(OptionEndpointSelection, w.selection)
+	}
+}
+
+// WithHedging returns a ClientOption that sends a GET also to the next endpoint if there is no response after delay.
+func WithHedging(delay time.Duration) settings.Option {
+	return withDuration{OptionHedgeDelay, delay}
+}
+
+// WithCompression returns a ClientOption that compresses request bodies of at least minSize bytes
+// with the content coding encoding, e.g. EncodingGzip or EncodingZstd. minSize defaults to DefaultCompressionMinSize.
+// Other codings than gzip, deflate and zstd need a Codec registered with RegisterCodec, NewRestClient fails otherwise.
+func WithCompression(encoding string, minSize int) settings.Option {
+	return withCompression{
+		encoding: encoding,
+		minSize:  minSize,
+	}
+}
+
+type withCompression struct {
+	encoding string
+	minSize  int
+}
+
+func (w withCompression) Apply(ds *settings.DialSettings) {
+	if w.minSize <= 0 {
+		w.minSize = DefaultCompressionMinSize
+	}
+	ds.SetOption(OptionCompression, fmt.Sprintf("%s,%d", w.encoding, w.minSize))
+}
+
+// WithAcceptEncoding returns a ClientOption that accepts responses in the given content codings,
+// in order of preference, and decodes them before they are logged, cached or unmarshalled.
+// A coding without a registered Codec, e.g. br, fails NewRestClient.
+func WithAcceptEncoding(encodings ...string) settings.Option {
+	return withAcceptEncoding(strings.Join(encodings, ", "))
+}
+
+type withAcceptEncoding string
+
+func (w withAcceptEncoding) Apply(ds *settings.DialSettings) {
+	ds.SetOption(OptionAcceptEncoding, string(w))
+}
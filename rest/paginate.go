@@ -0,0 +1,333 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultMaxPages limits the number of pages Paginate requests if PageOptions.MaxPages is not set
+	DefaultMaxPages = 1000
+)
+
+type (
+	// Page is the result of requesting one page from a list endpoint.
+	Page struct {
+		Request  *Request
+		Response *Response
+		Body     json.RawMessage
+		Items    int    // number of items on this page
+		URL      string // the URL the page was requested from, links are relative to it
+	}
+
+	// Pagination returns the request for the page following p, or nil if p is the last page.
+	Pagination interface {
+		Next(p *Page) (*Request, error)
+	}
+
+	// PageOptions controls how Paginate walks through a list endpoint.
+	PageOptions struct {
+		Pagination Pagination
+		ItemsField string // dotted path to the items in the body, empty if the body is the list itself
+		MaxPages   int
+	}
+
+	// pageStarter is implemented by paginations that add parameters to the request of the first page
+	pageStarter interface {
+		first(r *Request) *Request
+	}
+
+	linkPagination struct{}
+
+	cursorPagination struct {
+		field string
+		param string
+	}
+
+	offsetPagination struct {
+		offsetParam string
+		limitParam  string
+		limit       int
+	}
+)
+
+var (
+	// ErrMaxPagesExceeded indicates that a list has more pages than PageOptions.MaxPages
+	ErrMaxPagesExceeded = errors.New("max pages exceeded")
+	// ErrForeignLink indicates a link to the next page on another host than the client's endpoints
+	ErrForeignLink = errors.New("link to foreign host")
+)
+
+// Paginate requests r and all following pages and yields the items of type T one by one.
+// Iteration stops at the first error, which is yielded together with the zero value of T.
+// Links to pages on another scheme or host than the client's endpoints are refused, they would receive its credentials.
+func Paginate[T any](ctx context.Context, c *RestClient, r *Request, opts PageOptions) iter.Seq2[T, error] {
+	maxPages := opts.MaxPages
+	if maxPages <= 0 {
+		maxPages = DefaultMaxPages
+	}
+
+	return func(yield func(T, error) bool) {
+		var zero T
+
+		req := r
+		if s, ok := opts.Pagination.(pageStarter); ok {
+			req = s.first(r)
+		}
+		for n := 0; req != nil; n++ {
+			if n >= maxPages {
+				yield(zero, ErrMaxPagesExceeded)
+				return
+			}
+			if err := ctx.Err(); err != nil {
+				yield(zero, err)
+				return
+			}
+
+			u, err := req.URL(c.Settings.Endpoint)
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			var body json.RawMessage
+			resp, err := c.Call(ctx, req, &body)
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+
+			raw := body
+			if opts.ItemsField != "" {
+				if raw, err = lookupField(body, opts.ItemsField); err != nil {
+					yield(zero, err)
+					return
+				}
+			}
+
+			var items []T
+			if len(raw) > 0 && string(raw) != "null" {
+				if err := json.Unmarshal(raw, &items); err != nil {
+					yield(zero, err)
+					return
+				}
+			}
+			for _, item := range items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+
+			if opts.Pagination == nil {
+				return
+			}
+			req, err = opts.Pagination.Next(&Page{Request: req, Response: resp, Body: body, Items: len(items), URL: u})
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			if req != nil {
+				if err := c.checkOrigin(req); err != nil {
+					yield(zero, err)
+					return
+				}
+			}
+		}
+	}
+}
+
+// LinkPagination follows the rel="next" link of RFC 5988 Link headers. A relative link is resolved
+// against the URL of the current page, as defined by RFC 8288.
+func LinkPagination() Pagination {
+	return linkPagination{}
+}
+
+func (linkPagination) Next(p *Page) (*Request, error) {
+	if p.Response == nil || p.Response.Header == nil {
+		return nil, nil
+	}
+	next, ok := ParseLinkHeader(p.Response.Header.Values("Link"))["next"]
+	if !ok {
+		return nil, nil
+	}
+
+	if p.URL != "" {
+		base, err := url.Parse(p.URL)
+		if err != nil {
+			return nil, err
+		}
+		ref, err := url.Parse(next)
+		if err != nil {
+			return nil, fmt.Errorf("invalid link '%s': %w", next, err)
+		}
+		next = base.ResolveReference(ref).String()
+	}
+
+	// the link already contains all parameters and the query
+	req := NewRequest(p.Request.Method, next)
+	req.Route = p.Request.route()
+	req.Header = p.Request.Header.Clone()
+	req.Body = p.Request.Body
+	return req, nil
+}
+
+// CursorPagination reads the cursor for the next page from field in the body
+// and sends it as query parameter param. An empty or missing cursor ends the pagination.
+func CursorPagination(field, param string) Pagination {
+	return cursorPagination{field: field, param: param}
+}
+
+func (cp cursorPagination) Next(p *Page) (*Request, error) {
+	raw, err := lookupField(p.Body, cp.field)
+	if err != nil || len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	var cursor string
+	if err := json.Unmarshal(raw, &cursor); err != nil {
+		// numeric cursors are fine too
+		cursor = string(raw)
+	}
+	if cursor == "" {
+		return nil, nil
+	}
+	return p.Request.Clone().SetQuery(cp.param, cursor), nil
+}
+
+// OffsetPagination advances query parameter offsetParam by the number of items received,
+// requesting limit items per page, starting with offset 0 unless the first request sets another one.
+// The pagination ends with the first page holding less than limit items.
+func OffsetPagination(offsetParam, limitParam string, limit int) Pagination {
+	return offsetPagination{offsetParam: offsetParam, limitParam: limitParam, limit: limit}
+}
+
+func (op offsetPagination) first(r *Request) *Request {
+	req := r.Clone()
+	if req.Query.Get(op.offsetParam) == "" {
+		req.SetQuery(op.offsetParam, "0")
+	}
+	if op.limit > 0 {
+		req.SetQuery(op.limitParam, strconv.Itoa(op.limit))
+	}
+	return req
+}
+
+func (op offsetPagination) Next(p *Page) (*Request, error) {
+	if p.Items == 0 || (op.limit > 0 && p.Items < op.limit) {
+		return nil, nil
+	}
+
+	offset := 0
+	if o := p.Request.Query.Get(op.offsetParam); o != "" {
+		v, err := strconv.Atoi(o)
+		if err != nil {
+			return nil, fmt.Errorf("invalid offset '%s'", o)
+		}
+		offset = v
+	}
+
+	req := p.Request.Clone().SetQuery(op.offsetParam, strconv.Itoa(offset+p.Items))
+	if op.limit > 0 {
+		req.SetQuery(op.limitParam, strconv.Itoa(op.limit))
+	}
+	return req, nil
+}
+
+// ParseLinkHeader parses RFC 5988 Link header values into a map of rel -> url.
+func ParseLinkHeader(values []string) map[string]string {
+	links := make(map[string]string)
+
+	for _, value := range values {
+		// the targets may contain commas, e.g. "?fields=a,b", only the parameters are split
+		for rest := value; ; {
+			start := strings.IndexByte(rest, '<')
+			if start < 0 {
+				break
+			}
+			end := strings.IndexByte(rest[start:], '>')
+			if end < 0 {
+				break
+			}
+			target := rest[start+1 : start+end]
+			rest = rest[start+end+1:]
+
+			params := rest
+			if next := nextLink(rest); next >= 0 {
+				params, rest = rest[:next], rest[next+1:]
+			} else {
+				rest = ""
+			}
+
+			for _, param := range strings.Split(params, ";") {
+				key, val, ok := strings.Cut(strings.TrimSpace(param), "=")
+				if !ok || strings.ToLower(strings.TrimSpace(key)) != "rel" {
+					continue
+				}
+				for _, rel := range strings.Fields(strings.Trim(strings.TrimSpace(val), `"`)) {
+					links[strings.ToLower(rel)] = target
+				}
+			}
+		}
+	}
+	return links
+}
+
+// nextLink returns the index of the comma separating the parameters of a link from the next link, or -1.
+func nextLink(params string) int {
+	quoted := false
+	for i := 0; i < len(params); i++ {
+		switch params[i] {
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// checkOrigin returns ErrForeignLink if r addresses another scheme or host than the client's endpoints.
+func (c *RestClient) checkOrigin(r *Request) error {
+	target, err := r.URL(c.Settings.Endpoint)
+	if err != nil {
+		return err
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return err
+	}
+
+	endpoints := []string{c.Settings.Endpoint}
+	if v := c.Settings.GetOption(OptionEndpoints); v != "" {
+		endpoints = append(endpoints, strings.Split(v, ",")...)
+	}
+	for _, e := range endpoints {
+		if ep, err := url.Parse(strings.TrimSpace(e)); err == nil && strings.EqualFold(ep.Scheme, u.Scheme) && strings.EqualFold(ep.Host, u.Host) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: '%s'", ErrForeignLink, u.Redacted())
+}
+
+// lookupField returns the raw value at a dotted path like "data.items", or nil if it does not exist.
+func lookupField(body json.RawMessage, path string) (json.RawMessage, error) {
+	raw := body
+	for _, key := range strings.Split(path, ".") {
+		if len(raw) == 0 {
+			return nil, nil
+		}
+		fields := map[string]json.RawMessage{}
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			return nil, err
+		}
+		raw = fields[key]
+	}
+	return raw, nil
+}
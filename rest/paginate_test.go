@@ -0,0 +1,202 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testItem struct {
+	ID int `json:"id"`
+}
+
+func newPagedServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/link":
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			if page < 2 {
+				w.Header().Set("Link", fmt.Sprintf(`<http://%s/link?page=%d>; rel="next", <http://%s/link?page=2>; rel="last"`, r.Host, page+1, r.Host))
+			}
+			_, _ = fmt.Fprintf(w, `[{"id":%d},{"id":%d}]`, page*2, page*2+1)
+		case "/cursor":
+			switch r.URL.Query().Get("cursor") {
+			case "":
+				_, _ = w.Write([]byte(`{"data":{"items":[{"id":0},{"id":1}]},"next":"abc"}`))
+			case "abc":
+				_, _ = w.Write([]byte(`{"data":{"items":[{"id":2}]},"next":""}`))
+			default:
+				t.Errorf("unexpected cursor")
+			}
+		case "/offset":
+			// five items, one per page unless a limit is requested
+			offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+			limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+			if err != nil {
+				limit = 1
+			}
+			items := []string{}
+			for id := offset; id < min(offset+limit, 5); id++ {
+				items = append(items, fmt.Sprintf(`{"id":%d}`, id))
+			}
+			_, _ = fmt.Fprintf(w, "[%s]", strings.Join(items, ","))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func collect(seq func(func(testItem, error) bool)) ([]int, error) {
+	ids := []int{}
+	for item, err := range seq {
+		if err != nil {
+			return ids, err
+		}
+		ids = append(ids, item.ID)
+	}
+	return ids, nil
+}
+
+func TestPaginateLink(t *testing.T) {
+	srv := newPagedServer(t)
+	defer srv.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL))
+	assert.NoError(t, err)
+
+	ids, err := collect(Paginate[testItem](context.TODO(), cl, NewRequest("GET", "/link"), PageOptions{Pagination: LinkPagination()}))
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5}, ids)
+
+	// max pages guard
+	ids, err = collect(Paginate[testItem](context.TODO(), cl, NewRequest("GET", "/link"), PageOptions{Pagination: LinkPagination(), MaxPages: 2}))
+	assert.ErrorIs(t, err, ErrMaxPagesExceeded)
+	assert.Equal(t, []int{0, 1, 2, 3}, ids)
+}
+
+func TestPaginateCursor(t *testing.T) {
+	srv := newPagedServer(t)
+	defer srv.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL))
+	assert.NoError(t, err)
+
+	opts := PageOptions{Pagination: CursorPagination("next", "cursor"), ItemsField: "data.items"}
+	ids, err := collect(Paginate[testItem](context.TODO(), cl, NewRequest("GET", "/cursor"), opts))
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 2}, ids)
+}
+
+func TestPaginateOffset(t *testing.T) {
+	srv := newPagedServer(t)
+	defer srv.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL))
+	assert.NoError(t, err)
+
+	opts := PageOptions{Pagination: OffsetPagination("offset", "limit", 2)}
+	ids, err := collect(Paginate[testItem](context.TODO(), cl, NewRequest("GET", "/offset"), opts))
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 2, 3, 4}, ids)
+
+	// stop early
+	n := 0
+	for range Paginate[testItem](context.TODO(), cl, NewRequest("GET", "/offset"), opts) {
+		n++
+		if n == 3 {
+			break
+		}
+	}
+	assert.Equal(t, 3, n)
+}
+
+func TestPaginateCanceled(t *testing.T) {
+	srv := newPagedServer(t)
+	defer srv.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL))
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+
+	_, err = collect(Paginate[testItem](ctx, cl, NewRequest("GET", "/link"), PageOptions{Pagination: LinkPagination()}))
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestParseLinkHeader(t *testing.T) {
+	links := ParseLinkHeader([]string{`<https://x.com/a?page=2>; rel="next", <https://x.com/a?page=9>; rel="last"`, `<https://x.com/a?page=1>; rel="first prev"`})
+
+	assert.Equal(t, "https://x.com/a?page=2", links["next"])
+	assert.Equal(t, "https://x.com/a?page=9", links["last"])
+	assert.Equal(t, "https://x.com/a?page=1", links["first"])
+	assert.Equal(t, "https://x.com/a?page=1", links["prev"])
+
+	// commas in targets and quoted parameters
+	links = ParseLinkHeader([]string{`<https://x.com/a?fields=a,b&page=2>; title="x, y"; rel="next",<https://x.com/a?fields=a,b&page=9>;rel=last`})
+	assert.Equal(t, "https://x.com/a?fields=a,b&page=2", links["next"])
+	assert.Equal(t, "https://x.com/a?fields=a,b&page=9", links["last"])
+}
+
+func TestPaginateRelativeLink(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.RequestURI())
+		switch r.URL.Query().Get("page") {
+		case "":
+			w.Header().Set("Link", `</v1/items?page=2>; rel="next"`)
+		case "2":
+			w.Header().Set("Link", `<items?page=3>; rel="next"`)
+		}
+		_, _ = w.Write([]byte(`[{"id":0}]`))
+	}))
+	defer srv.Close()
+
+	// links are relative to the page, not appended to the endpoint
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL+"/v1"))
+	assert.NoError(t, err)
+
+	ids, err := collect(Paginate[testItem](context.TODO(), cl, NewRequest("GET", "/items"), PageOptions{Pagination: LinkPagination()}))
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 0, 0}, ids)
+	assert.Equal(t, []string{"/v1/items", "/v1/items?page=2", "/v1/items?page=3"}, paths)
+}
+
+func TestPaginateForeignLink(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NotEqual(t, "2", r.URL.Query().Get("page"))
+		w.Header().Set("Link", `<https://evil.example.com/link?page=2>; rel="next"`)
+		_, _ = w.Write([]byte(`[{"id":0}]`))
+	}))
+	defer srv.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL), WithToken("id", "token"))
+	assert.NoError(t, err)
+
+	ids, err := collect(Paginate[testItem](context.TODO(), cl, NewRequest("GET", "/link"), PageOptions{Pagination: LinkPagination()}))
+	assert.ErrorIs(t, err, ErrForeignLink)
+	assert.Equal(t, []int{0}, ids)
+}
+
+func TestPaginateLinkRoute(t *testing.T) {
+	srv := newPagedServer(t)
+	defer srv.Close()
+
+	metrics := NewPrometheusMetrics()
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL), WithMetrics(metrics))
+	assert.NoError(t, err)
+
+	_, err = collect(Paginate[testItem](context.TODO(), cl, NewRequest("GET", "/link"), PageOptions{Pagination: LinkPagination()}))
+	assert.NoError(t, err)
+
+	var buf strings.Builder
+	_, _ = metrics.WriteTo(&buf)
+	assert.Contains(t, buf.String(), `rest_client_requests_total{method="GET",route="/link",status="2xx"} 3`)
+	assert.NotContains(t, buf.String(), "page=")
+}
@@ -0,0 +1,165 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type (
+	// RateLimiter is a token bucket allowing rate requests per second with bursts of up to burst requests.
+	// It also backs off if the API asks to, i.e. on Retry-After or an exhausted X-RateLimit-Remaining.
+	RateLimiter struct {
+		mu           sync.Mutex
+		rate         float64
+		burst        float64
+		tokens       float64
+		last         time.Time
+		blockedUntil time.Time
+	}
+
+	// rateLimitTransport waits for a token of the route's limiter, or the client's, before each attempt.
+	rateLimitTransport struct {
+		InnerTransport http.RoundTripper
+		limiter        *RateLimiter
+		routes         map[string]*RateLimiter
+	}
+)
+
+// NewRateLimiter returns a limiter for rate requests/sec. A rate <= 0 does not limit
+// the requests but still honors the backoff requested by the API.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until a request is allowed or ctx is done.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	for {
+		d := l.reserve()
+		if d <= 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Allow reports whether a request may happen now, consuming a token if so.
+func (l *RateLimiter) Allow() bool {
+	return l.reserve() <= 0
+}
+
+// Update adapts the limiter to the rate limit headers of resp. The backoff is capped at MaxRetryAfter.
+func (l *RateLimiter) Update(resp *http.Response) {
+	now := time.Now()
+	until := time.Time{}
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		if d, ok := RetryAfter(resp.Header, now); ok {
+			until = now.Add(d)
+		}
+	}
+	if remaining := resp.Header.Get("X-RateLimit-Remaining"); remaining == "0" {
+		if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			// either a unix timestamp or the seconds until the reset
+			if reset > 1_000_000_000 {
+				until = laterOf(until, time.Unix(reset, 0))
+			} else {
+				until = laterOf(until, now.Add(time.Duration(reset)*time.Second))
+			}
+		}
+	}
+
+	if !until.IsZero() {
+		// like the retry delay, a single response must not block the client for longer than MaxRetryAfter
+		if limit := now.Add(MaxRetryAfter); until.After(limit) {
+			until = limit
+		}
+
+		l.mu.Lock()
+		l.blockedUntil = laterOf(l.blockedUntil, until)
+		l.mu.Unlock()
+	}
+}
+
+func (l *RateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(l.blockedUntil) {
+		return l.blockedUntil.Sub(now)
+	}
+	if l.rate <= 0 {
+		return 0
+	}
+
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+	return time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
+
+// RetryAfter parses the Retry-After header, given in seconds or as HTTP date.
+func RetryAfter(h http.Header, now time.Time) (time.Duration, bool) {
+	ra := h.Get("Retry-After")
+	if ra == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(ra); err == nil {
+		return time.Duration(secs) * time.Second, secs >= 0
+	}
+	if t, err := http.ParseTime(ra); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// RoundTrip waits for the limiter responsible for the request and adapts it to the response.
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	limiter := t.limiter
+	if state := callStateFromContext(req.Context()); state != nil {
+		if l, ok := t.routes[req.Method+" "+state.route]; ok {
+			limiter = l
+		} else if l, ok := t.routes[state.route]; ok {
+			limiter = l
+		}
+	}
+
+	if err := limiter.Wait(req.Context()); err != nil {
+		return nil, err
+	}
+
+	resp, err := t.InnerTransport.RoundTrip(req)
+	if resp != nil {
+		limiter.Update(resp)
+	}
+	return resp, err
+}
+
+func laterOf(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,128 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := NewRateLimiter(20, 2)
+
+	// the burst is available immediately
+	assert.True(t, l.Allow())
+	assert.True(t, l.Allow())
+	assert.False(t, l.Allow())
+
+	start := time.Now()
+	assert.NoError(t, l.Wait(context.TODO()))
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer cancel()
+	assert.Error(t, l.Wait(ctx))
+}
+
+func TestRateLimiterUpdate(t *testing.T) {
+	l := NewRateLimiter(0, 1)
+	assert.True(t, l.Allow())
+
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}
+	resp.Header.Set("Retry-After", "60")
+	l.Update(resp)
+	assert.False(t, l.Allow())
+
+	l = NewRateLimiter(0, 1)
+	resp = &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+	resp.Header.Set("X-RateLimit-Remaining", "0")
+	resp.Header.Set("X-RateLimit-Reset", "60")
+	l.Update(resp)
+	assert.False(t, l.Allow())
+}
+
+func TestRateLimiterUpdateCapped(t *testing.T) {
+	for _, h := range []http.Header{
+		{"Retry-After": {"86400"}},
+		{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {strconv.FormatInt(time.Now().Add(24*time.Hour).Unix(), 10)}},
+	} {
+		l := NewRateLimiter(0, 1)
+		l.Update(&http.Response{StatusCode: http.StatusTooManyRequests, Header: h})
+
+		d := l.reserve()
+		assert.Greater(t, d, MaxRetryAfter-time.Second)
+		assert.LessOrEqual(t, d, MaxRetryAfter)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Now()
+
+	h := http.Header{}
+	_, ok := RetryAfter(h, now)
+	assert.False(t, ok)
+
+	h.Set("Retry-After", "2")
+	d, ok := RetryAfter(h, now)
+	assert.True(t, ok)
+	assert.Equal(t, 2*time.Second, d)
+
+	h.Set("Retry-After", now.Add(10*time.Second).UTC().Format(http.TimeFormat))
+	d, ok = RetryAfter(h, now)
+	assert.True(t, ok)
+	assert.InDelta(t, 10*time.Second, d, float64(time.Second))
+}
+
+func TestRetryOnTooManyRequests(t *testing.T) {
+	var calls atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL))
+	assert.NoError(t, err)
+
+	start := time.Now()
+	meta, err := cl.Call(context.TODO(), NewRequest("GET", "/"), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, meta.StatusCode)
+	assert.Equal(t, 2, meta.Attempts)
+	assert.GreaterOrEqual(t, time.Since(start), time.Second)
+}
+
+func TestRouteRateLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL), WithRateLimit(1000, 10), WithRouteRateLimit("GET /slow/{id}", 20, 1))
+	assert.NoError(t, err)
+	assert.Equal(t, "1000,10", cl.Settings.GetOption(OptionRateLimit))
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		_, err := cl.GET("/fast", nil)
+		assert.NoError(t, err)
+	}
+	assert.Less(t, time.Since(start), 40*time.Millisecond)
+
+	start = time.Now()
+	for i := 0; i < 3; i++ {
+		_, err := cl.Do(context.TODO(), NewRequest("GET", "/slow/{id}").SetParam("id", "1"), nil)
+		assert.NoError(t, err)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+}
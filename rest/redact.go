@@ -0,0 +1,132 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// RedactedValue replaces sensitive values in logs
+	RedactedValue = "***"
+)
+
+type (
+	// Redactor removes sensitive values from what LoggingTransport logs.
+	Redactor struct {
+		Headers []string // header names, case-insensitive
+		Fields  []string // JSON key paths like "auth.token", a plain key matches at any depth
+		Query   []string // query parameter names
+	}
+)
+
+// DefaultRedactor covers the usual auth headers and the secrets of settings.Credentials.
+func DefaultRedactor() *Redactor {
+	return &Redactor{
+		Headers: []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"},
+		Fields:  []string{"client_secret", "token", "access_token", "refresh_token", "id_token", "password", "secret"},
+		Query:   []string{"client_secret", "token", "access_token", "api_key", "key", "password", "signature"},
+	}
+}
+
+// Header returns a copy of h with the values of sensitive headers redacted.
+func (r *Redactor) Header(h http.Header) http.Header {
+	dup := h.Clone()
+	for _, name := range r.Headers {
+		if _, ok := dup[http.CanonicalHeaderKey(name)]; ok {
+			dup.Set(name, RedactedValue)
+		}
+	}
+	return dup
+}
+
+// URI returns the request URI of u with sensitive query parameters redacted.
+func (r *Redactor) URI(u *url.URL) string {
+	if u.RawQuery == "" || len(r.Query) == 0 {
+		return u.RequestURI()
+	}
+
+	q := u.Query()
+	redacted := false
+	for _, name := range r.Query {
+		for k := range q {
+			if strings.EqualFold(k, name) {
+				q.Set(k, RedactedValue)
+				redacted = true
+			}
+		}
+	}
+	if !redacted {
+		return u.RequestURI()
+	}
+
+	dup := *u
+	dup.RawQuery = q.Encode()
+	return dup.RequestURI()
+}
+
+// Body redacts sensitive fields of a JSON body. Anything that is not JSON is returned unchanged.
+func (r *Redactor) Body(data []byte) []byte {
+	if len(r.Fields) == 0 || len(data) == 0 {
+		return data
+	}
+
+	var v interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&v); err != nil {
+		return data
+	}
+
+	if !r.redactValue(v, "") {
+		return data
+	}
+	redacted, err := json.Marshal(v)
+	if err != nil {
+		return data
+	}
+	return redacted
+}
+
+// redactValue walks v and returns true if anything was redacted.
+func (r *Redactor) redactValue(v interface{}, path string) bool {
+	redacted := false
+
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, child := range t {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			if r.isSensitive(k, p) {
+				t[k] = RedactedValue
+				redacted = true
+			} else if r.redactValue(child, p) {
+				redacted = true
+			}
+		}
+	case []interface{}:
+		for _, child := range t {
+			if r.redactValue(child, path) {
+				redacted = true
+			}
+		}
+	}
+	return redacted
+}
+
+func (r *Redactor) isSensitive(key, path string) bool {
+	for _, f := range r.Fields {
+		if strings.Contains(f, ".") {
+			if strings.EqualFold(f, path) {
+				return true
+			}
+		} else if strings.EqualFold(f, key) {
+			return true
+		}
+	}
+	return false
+}
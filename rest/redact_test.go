@@ -0,0 +1,76 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactHeader(t *testing.T) {
+	h := http.Header{}
+	h.Set("Authorization", "Bearer secret")
+	h.Set("Content-Type", "application/json")
+
+	redacted := DefaultRedactor().Header(h)
+	assert.Equal(t, RedactedValue, redacted.Get("Authorization"))
+	assert.Equal(t, "application/json", redacted.Get("Content-Type"))
+	assert.Equal(t, "Bearer secret", h.Get("Authorization")) // unchanged
+}
+
+func TestRedactURI(t *testing.T) {
+	u, _ := url.Parse("https://x.com/a?access_token=secret&page=2")
+	assert.Equal(t, "/a?access_token=%2A%2A%2A&page=2", DefaultRedactor().URI(u))
+
+	u, _ = url.Parse("https://x.com/a?page=2")
+	assert.Equal(t, "/a?page=2", DefaultRedactor().URI(u))
+}
+
+func TestRedactBody(t *testing.T) {
+	r := &Redactor{Fields: []string{"client_secret", "auth.key"}}
+
+	body := r.Body([]byte(`{"client_id":"id","client_secret":"secret","auth":{"key":"k","x":1},"items":[{"client_secret":"s2"}],"key":"visible"}`))
+	assert.JSONEq(t, `{"client_id":"id","client_secret":"***","auth":{"key":"***","x":1},"items":[{"client_secret":"***"}],"key":"visible"}`, string(body))
+
+	// not JSON, nothing to redact
+	assert.Equal(t, []byte("client_secret=x"), r.Body([]byte("client_secret=x")))
+	assert.Equal(t, []byte(`{"a":1}`), r.Body([]byte(`{"a":1}`)))
+}
+
+func TestLoggingTransportRedaction(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger, level := log.Logger, zerolog.GlobalLevel()
+	log.Logger = zerolog.New(&buf)
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+	defer func() {
+		log.Logger = logger
+		zerolog.SetGlobalLevel(level)
+	}()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"token":"response-secret","name":"foo"}`))
+	}))
+	defer srv.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL), WithToken("id", "bearer-secret"))
+	assert.NoError(t, err)
+
+	r := NewRequest("POST", "/login").AddQuery("access_token", "query-secret").SetBody(map[string]string{"client_secret": "body-secret"})
+	_, err = cl.Do(context.TODO(), r, nil)
+	assert.NoError(t, err)
+
+	out := buf.String()
+	assert.Contains(t, out, "REQ")
+	assert.Contains(t, out, "RESP")
+	assert.Contains(t, out, "foo")
+	for _, secret := range []string{"bearer-secret", "query-secret", "body-secret", "response-secret"} {
+		assert.NotContains(t, out, secret)
+	}
+}
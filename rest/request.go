@@ -0,0 +1,177 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type (
+	// Request describes an API call relative to the client's endpoint.
+	// Path may contain placeholders like "/users/{id}" that are expanded from Params.
+	Request struct {
+		Method string
+		Path   string
+		Route  string // identifies the endpoint in metrics, traces and route rate limits, see SetRoute
+		Params map[string]string
+		Query  url.Values
+		Header http.Header
+		Body   interface{}
+	}
+
+	// Payload is a request body with its own encoding. Any other body is sent as JSON.
+	Payload interface {
+		Encode() (contentType string, data []byte, err error)
+	}
+)
+
+// NewRequest returns a request for method and path, e.g. NewRequest("GET", "/users/{id}").
+func NewRequest(method, path string) *Request {
+	return &Request{
+		Method: method,
+		Path:   path,
+		Params: make(map[string]string),
+		Query:  make(url.Values),
+		Header: make(http.Header),
+	}
+}
+
+// SetParam sets the value of path placeholder {name}.
+func (r *Request) SetParam(name, value string) *Request {
+	if r.Params == nil {
+		r.Params = make(map[string]string)
+	}
+	r.Params[name] = value
+	return r
+}
+
+// AddQuery adds value to the query parameter key.
+func (r *Request) AddQuery(key, value string) *Request {
+	if r.Query == nil {
+		r.Query = make(url.Values)
+	}
+	r.Query.Add(key, value)
+	return r
+}
+
+// SetQuery replaces any existing values of the query parameter key.
+func (r *Request) SetQuery(key, value string) *Request {
+	if r.Query == nil {
+		r.Query = make(url.Values)
+	}
+	r.Query.Set(key, value)
+	return r
+}
+
+// SetHeader sets a request header, overriding any default set by the client.
+func (r *Request) SetHeader(key, value string) *Request {
+	if r.Header == nil {
+		r.Header = make(http.Header)
+	}
+	r.Header.Set(key, value)
+	return r
+}
+
+// SetBody sets the payload that is sent as JSON, unless it implements Payload.
+func (r *Request) SetBody(body interface{}) *Request {
+	r.Body = body
+	return r
+}
+
+// Clone returns a copy of the request. Body is shared, everything else is copied.
+func (r *Request) Clone() *Request {
+	dup := &Request{
+		Method: r.Method,
+		Path:   r.Path,
+		Route:  r.Route,
+		Params: make(map[string]string, len(r.Params)),
+		Query:  make(url.Values, len(r.Query)),
+		Header: r.Header.Clone(),
+		Body:   r.Body,
+	}
+	for k, v := range r.Params {
+		dup.Params[k] = v
+	}
+	for k, v := range r.Query {
+		dup.Query[k] = append([]string(nil), v...)
+	}
+	if dup.Header == nil {
+		dup.Header = make(http.Header)
+	}
+	return dup
+}
+
+// SetRoute sets the label identifying the endpoint in metrics, traces and route rate limits,
+// e.g. "/users/{id}" for a path built without placeholders.
+func (r *Request) SetRoute(route string) *Request {
+	r.Route = route
+	return r
+}
+
+// route returns the Route of the request, or its Path without query and, for a link, without scheme and host.
+func (r *Request) route() string {
+	if r.Route != "" {
+		return r.Route
+	}
+	path, _, _ := strings.Cut(r.Path, "?")
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		if u, err := url.Parse(path); err == nil {
+			path = u.Path
+		}
+	}
+	return path
+}
+
+// Expand returns the path with all placeholders replaced by their escaped parameter values.
+// A placeholder without a parameter is an error, whether or not other parameters are set.
+// Only the part before '?' is expanded, a query in the path is kept as it is.
+func (r *Request) Expand() (string, error) {
+	var sb strings.Builder
+	path, query, hasQuery := strings.Cut(r.Path, "?")
+	for {
+		start := strings.IndexByte(path, '{')
+		if start < 0 {
+			sb.WriteString(path)
+			break
+		}
+		end := strings.IndexByte(path[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated placeholder in '%s'", r.Path)
+		}
+		name := path[start+1 : start+end]
+		value, ok := r.Params[name]
+		if !ok {
+			return "", fmt.Errorf("missing path parameter '%s'", name)
+		}
+		sb.WriteString(path[:start])
+		sb.WriteString(url.PathEscape(value))
+		path = path[start+end+1:]
+	}
+	if hasQuery {
+		sb.WriteString("?" + query)
+	}
+	return sb.String(), nil
+}
+
+// URL returns the full request URL relative to endpoint, including the encoded query.
+// An absolute path, e.g. a link returned by the API, is used without the endpoint.
+func (r *Request) URL(endpoint string) (string, error) {
+	path, err := r.Expand()
+	if err != nil {
+		return "", err
+	}
+
+	u := path
+	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+		u = endpoint + path
+	}
+	if len(r.Query) > 0 {
+		if strings.Contains(u, "?") {
+			u = u + "&" + r.Query.Encode()
+		} else {
+			u = u + "?" + r.Query.Encode()
+		}
+	}
+	return u, nil
+}
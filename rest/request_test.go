@@ -0,0 +1,79 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestExpand(t *testing.T) {
+	r := NewRequest("GET", "/users/{id}/items/{item}").SetParam("id", "42").SetParam("item", "a b/c")
+
+	path, err := r.Expand()
+	assert.NoError(t, err)
+	assert.Equal(t, "/users/42/items/a%20b%2Fc", path)
+
+	// missing parameter
+	r = NewRequest("GET", "/users/{id}/items/{item}").SetParam("id", "42")
+	_, err = r.Expand()
+	assert.Error(t, err)
+
+	// no parameters at all
+	r = NewRequest("GET", "/users/{id}")
+	_, err = r.Expand()
+	assert.Error(t, err)
+
+	// a path without placeholders needs none
+	r = NewRequest("GET", "/users")
+	path, err = r.Expand()
+	assert.NoError(t, err)
+	assert.Equal(t, "/users", path)
+
+	// braces in the query are not placeholders
+	r = NewRequest("GET", `/users/{id}?filter={"a":1}`).SetParam("id", "42")
+	path, err = r.Expand()
+	assert.NoError(t, err)
+	assert.Equal(t, `/users/42?filter={"a":1}`, path)
+}
+
+func TestLegacyQueryWithBraces(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, `{"a":1}`, r.URL.Query().Get("filter"))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL))
+	assert.NoError(t, err)
+
+	status, err := cl.GET(`/search?filter={"a":1}`, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, status)
+}
+
+func TestRequestURL(t *testing.T) {
+	r := NewRequest("GET", "/search").AddQuery("q", "a&b=c").AddQuery("tag", "x").AddQuery("tag", "y")
+
+	u, err := r.URL("https://api.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://api.example.com/search?q=a%26b%3Dc&tag=x&tag=y", u)
+
+	r = NewRequest("GET", "/search?page=2").SetQuery("q", "z")
+	u, err = r.URL("https://api.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://api.example.com/search?page=2&q=z", u)
+}
+
+func TestRequestClone(t *testing.T) {
+	r := NewRequest("GET", "/users/{id}").SetParam("id", "1").AddQuery("a", "b").SetHeader("X-Foo", "bar")
+	dup := r.Clone()
+	assert.Equal(t, r, dup)
+
+	dup.SetParam("id", "2").AddQuery("a", "c").SetHeader("X-Foo", "baz")
+	assert.Equal(t, "1", r.Params["id"])
+	assert.Equal(t, []string{"b"}, r.Query["a"])
+	assert.Equal(t, "bar", r.Header.Get("X-Foo"))
+}
@@ -0,0 +1,167 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+type (
+	// Response holds the metadata of a completed API call.
+	Response struct {
+		StatusCode int
+		Header     http.Header
+		Duration   time.Duration
+		Attempts   int
+		RequestID  string // the ID the client sent and logged
+		// ServerRequestID is the X-Request-ID of the response, if the server assigned its own ID
+		ServerRequestID string
+	}
+
+	// callState is shared by all layers of the transport for one logical call.
+	callState struct {
+		route     string // the path template, e.g. "/users/{id}"
+		requestID string
+		stream    bool // the body is consumed while it arrives, see open
+		attempts  atomic.Int32
+	}
+
+	// attemptTransport sits below the retry layer and sees every single attempt.
+	attemptTransport struct {
+		InnerTransport http.RoundTripper
+		Metrics        Metrics
+		Tracer         Tracer
+	}
+)
+
+var (
+	ctxKeyCallState = &contextKey{"CallState"}
+)
+
+// Call executes an arbitrary request just like Do but returns the response metadata
+// instead of just the status. The returned Response is never nil.
+func (c *RestClient) Call(ctx context.Context, r *Request, response interface{}) (*Response, error) {
+	state := &callState{
+		route:     r.route(),
+		requestID: RequestIDFromContext(ctx),
+	}
+	if state.requestID == "" {
+		state.requestID = XID()
+	}
+	start := time.Now()
+
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	req, err := c.request(context.WithValue(ctx, ctxKeyCallState, state), r)
+	if err != nil {
+		return &Response{StatusCode: http.StatusBadRequest, RequestID: state.requestID}, &Error{StatusCode: http.StatusBadRequest, RequestID: state.requestID, Err: err}
+	}
+
+	resp, err := c.roundTrip(req, response)
+
+	meta := &Response{
+		StatusCode: http.StatusInternalServerError,
+		Duration:   time.Since(start),
+		Attempts:   int(state.attempts.Load()),
+		RequestID:  state.requestID,
+	}
+	if resp != nil {
+		meta.Header = resp.Header
+		meta.ServerRequestID = resp.Header.Get("X-Request-ID")
+		if err == nil || resp.StatusCode > http.StatusNoContent {
+			meta.StatusCode = resp.StatusCode
+		}
+		if meta.Attempts == 0 {
+			meta.Attempts = 1 // a custom transport without attempt tracking
+		}
+	}
+
+	if err != nil {
+		apiErr, ok := err.(*Error)
+		if !ok {
+			if ctx.Err() != nil && resp == nil {
+				err = fmt.Errorf("%s %s: %w", r.Method, r.Path, ctx.Err()) // the retry transport hides the cause
+			}
+			apiErr = &Error{StatusCode: meta.StatusCode, Err: err}
+		}
+		apiErr.RequestID = meta.RequestID
+		apiErr.ServerRequestID = meta.ServerRequestID
+		return meta, apiErr
+	}
+	return meta, nil
+}
+
+func callStateFromContext(ctx context.Context) *callState {
+	if state, ok := ctx.Value(ctxKeyCallState).(*callState); ok {
+		return state
+	}
+	return nil
+}
+
+// isOpened reports whether req belongs to a call of open, whose body must not be buffered.
+func isOpened(req *http.Request) bool {
+	state := callStateFromContext(req.Context())
+	return state != nil && state.stream
+}
+
+// requestID returns the ID of the call req belongs to.
+func requestID(req *http.Request) string {
+	if state := callStateFromContext(req.Context()); state != nil {
+		return state.requestID
+	}
+	if id := req.Header.Get("X-Request-ID"); id != "" {
+		return id
+	}
+	return XID()
+}
+
+// RoundTrip counts and logs the attempt and passes the request on
+func (t *attemptTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	attempt := 1
+	if state := callStateFromContext(req.Context()); state != nil {
+		attempt = int(state.attempts.Add(1))
+	}
+
+	if progress := progressFromContext(req.Context()); progress != nil && req.Body != nil && req.Body != http.NoBody {
+		// every attempt sends the body again
+		req = req.Clone(req.Context())
+		req.Body = &progressReader{ReadCloser: req.Body, total: req.ContentLength, progress: progress}
+	}
+
+	req, span := startSpan(t.Tracer, req, "attempt")
+	if span != nil {
+		span.SetAttribute("attempt", attempt)
+	}
+
+	start := time.Now()
+	resp, err := t.InnerTransport.RoundTrip(propagate(req))
+	endSpan(span, resp, err)
+
+	if t.Metrics != nil {
+		route := routeOf(req)
+		labels := Labels{"method": req.Method, "route": route, "status": statusClass(resp, err), "attempt": strconv.Itoa(attempt)}
+		t.Metrics.IncCounter(MetricAttempts, labels)
+		t.Metrics.Observe(MetricAttemptDuration, time.Since(start).Seconds(), Labels{"method": req.Method, "route": route, "status": labels["status"]})
+		if attempt > 1 {
+			t.Metrics.IncCounter(MetricRetries, Labels{"method": req.Method, "route": route})
+		}
+	}
+
+	if log.Debug().Enabled() {
+		if err != nil {
+			log.Debug().Str("m", req.Method).Int("attempt", attempt).Err(err).Str("uid", requestID(req)).Msg("ATTEMPT")
+		} else {
+			log.Debug().Str("m", req.Method).Int("attempt", attempt).Int("status", resp.StatusCode).Str("uid", requestID(req)).Msg("ATTEMPT")
+		}
+	}
+	return resp, err
+}
@@ -0,0 +1,65 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCallResponseMetadata(t *testing.T) {
+	var calls atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("X-Request-ID", "server-id")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":"1"}`))
+	}))
+	defer srv.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL))
+	assert.NoError(t, err)
+
+	resp := map[string]string{}
+	meta, err := cl.Call(context.TODO(), NewRequest(http.MethodPost, "/items").SetBody(resp), &resp)
+	assert.NoError(t, err)
+	assert.NotNil(t, meta)
+
+	assert.Equal(t, http.StatusCreated, meta.StatusCode)
+	assert.Equal(t, 2, meta.Attempts)
+	assert.Equal(t, `"v1"`, meta.Header.Get("ETag"))
+	assert.Equal(t, "server-id", meta.ServerRequestID)
+	assert.NotEqual(t, "server-id", meta.RequestID) // the ID the client sent
+	assert.Greater(t, meta.Duration.Nanoseconds(), int64(0))
+	assert.Equal(t, "1", resp["id"])
+}
+
+func TestCallError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte("not found"))
+	}))
+	defer srv.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL))
+	assert.NoError(t, err)
+
+	meta, err := cl.Call(context.TODO(), NewRequest(http.MethodGet, "/missing"), nil)
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusNotFound, meta.StatusCode)
+	assert.Equal(t, 1, meta.Attempts)
+
+	// invalid request, nothing is sent
+	meta, err = cl.Call(context.TODO(), NewRequest(http.MethodGet, "/{id}").SetParam("x", "y"), nil)
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusBadRequest, meta.StatusCode)
+	assert.Equal(t, 0, meta.Attempts)
+}
@@ -9,6 +9,7 @@ import (
 	"io"
 	"math"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/PuerkitoBio/rehttp"
@@ -25,6 +26,9 @@ const (
 
 	ApiAgent = "txsvc/rest"
 
+	// MaxRetryAfter caps the delay a Retry-After header can impose on a retry
+	MaxRetryAfter = 30 * time.Second
+
 	// format error messages
 	MsgStatus = "%s. status: %d"
 )
@@ -35,10 +39,25 @@ type (
 		HttpClient *http.Client
 		Settings   *settings.DialSettings
 		Trace      string
+
+		transport http.RoundTripper
+		cache     Cache
+		redactor  *Redactor
+		metrics   Metrics
+		tracer    Tracer
+		signer    Signer
+		timeout   time.Duration
+		stream    http.RoundTripper // without the attempt timeout that would cut off long-lived responses
+
+		compression    string // content coding of request bodies
+		compressionMin int
 	}
 
 	LoggingTransport struct {
 		InnerTransport http.RoundTripper
+		Redactor       *Redactor // DefaultRedactor() if nil
+		Metrics        Metrics   // no metrics if nil
+		Tracer         Tracer    // no spans if nil, trace headers of the context are propagated anyway
 	}
 
 	contextKey struct {
@@ -51,6 +70,8 @@ var (
 	ErrApiInvocationError = errors.New("api invocation error")
 
 	ctxKeyRequestStart = &contextKey{"RequestStart"}
+
+	defaultRedactor = DefaultRedactor()
 )
 
 func NewRestClient(ctx context.Context, opts ...settings.Option) (*RestClient, error) {
@@ -72,80 +93,194 @@ func NewRestClient(ctx context.Context, opts ...settings.Option) (*RestClient, e
 		return nil, fmt.Errorf("missing HTTP_ENDPOINT")
 	}
 
-	return &RestClient{
-		HttpClient: NewLoggingTransport(http.DefaultTransport),
-		Settings:   ds,
-		Trace:      stdlib.GetString(FORCE_TRACE, ""),
-	}, nil
+	c := &RestClient{
+		Settings: ds,
+		Trace:    stdlib.GetString(FORCE_TRACE, ""),
+	}
+
+	// apply options that configure the client itself rather than its settings
+	for _, opt := range opts {
+		if co, ok := opt.(clientOption); ok {
+			co.applyClient(c)
+		}
+	}
+
+	// a signer set by WithSigner takes precedence over the scheme selected by WithSigning
+	if scheme := ds.GetOption(OptionSigning); scheme != "" && c.signer == nil {
+		var err error
+		if c.signer, err = NewSigner(scheme, ds.Credentials); err != nil {
+			return nil, err
+		}
+	}
+
+	if v := ds.GetOption(OptionCompression); v != "" {
+		var err error
+		if c.compression, c.compressionMin, err = parseCompression(v); err != nil {
+			return nil, err
+		}
+	}
+	if v := ds.GetOption(OptionAcceptEncoding); v != "" {
+		if err := checkAcceptEncoding(v); err != nil {
+			return nil, err
+		}
+	}
+
+	// a custom transport takes precedence over the options configuring the default one
+	base := c.transport
+	if base == nil {
+		var err error
+		if base, err = newBaseTransport(ds); err != nil {
+			return nil, err
+		}
+	}
+
+	inner, err := c.newTransport(base)
+	if err != nil {
+		return nil, err
+	}
+	lt := newLoggingTransport(inner, c.metrics, c.tracer)
+	lt.Redactor = c.redactor
+	c.stream = lt
+
+	attemptTimeout, err := durationOption(ds, OptionAttemptTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if attemptTimeout > 0 {
+		if rt, ok := lt.InnerTransport.(*rehttp.Transport); ok {
+			rt.PerAttemptTimeout = attemptTimeout
+		}
+		stream := newLoggingTransport(inner, c.metrics, c.tracer)
+		stream.Redactor = c.redactor
+		c.stream = stream
+	}
+	if c.timeout, err = durationOption(ds, OptionTimeout); err != nil {
+		return nil, err
+	}
+
+	c.HttpClient = &http.Client{Transport: lt}
+	return c, nil
 }
 
+// SetClient replaces the client that sends the requests. Downloads and streams, e.g. Download and Events,
+// use its transport from then on too, without the attempt timeout of the client's own transport.
 func (c *RestClient) SetClient(cl *http.Client) {
 	c.HttpClient = cl
+	c.stream = nil
 }
 
 // GET is used to request data from the API. No payload, only queries!
 func (c *RestClient) GET(uri string, response interface{}) (int, error) {
-	return c.request("GET", fmt.Sprintf("%s%s", c.Settings.Endpoint, uri), nil, response)
+	return c.Do(context.Background(), NewRequest(http.MethodGet, uri), response)
 }
 
 func (c *RestClient) POST(uri string, request, response interface{}) (int, error) {
-	return c.request("POST", fmt.Sprintf("%s%s", c.Settings.Endpoint, uri), request, response)
+	return c.Do(context.Background(), NewRequest(http.MethodPost, uri).SetBody(request), response)
 }
 
 func (c *RestClient) PUT(uri string, request, response interface{}) (int, error) {
-	return c.request("PUT", fmt.Sprintf("%s%s", c.Settings.Endpoint, uri), request, response)
+	return c.Do(context.Background(), NewRequest(http.MethodPut, uri).SetBody(request), response)
+}
+
+func (c *RestClient) PATCH(uri string, request, response interface{}) (int, error) {
+	return c.Do(context.Background(), NewRequest(http.MethodPatch, uri).SetBody(request), response)
 }
 
 func (c *RestClient) DELETE(uri string, request, response interface{}) (int, error) {
-	return c.request("DELETE", fmt.Sprintf("%s%s", c.Settings.Endpoint, uri), request, response)
+	return c.Do(context.Background(), NewRequest(http.MethodDelete, uri).SetBody(request), response)
 }
 
-func (c *RestClient) request(method, url string, request, response interface{}) (int, error) {
-	var req *http.Request
+// HEAD only returns the status, there is no payload to decode.
+func (c *RestClient) HEAD(uri string) (int, error) {
+	return c.Do(context.Background(), NewRequest(http.MethodHead, uri), nil)
+}
 
-	if request != nil {
-		p, err := json.Marshal(&request)
-		if err != nil {
-			return http.StatusInternalServerError, err
-		}
+func (c *RestClient) OPTIONS(uri string, response interface{}) (int, error) {
+	return c.Do(context.Background(), NewRequest(http.MethodOptions, uri), response)
+}
 
-		req, err = http.NewRequest(method, url, bytes.NewBuffer(p))
-		if err != nil {
-			return http.StatusBadRequest, err
+// Do executes an arbitrary request and unmarshals the reply into response, if not nil.
+func (c *RestClient) Do(ctx context.Context, r *Request, response interface{}) (int, error) {
+	resp, err := c.Call(ctx, r, response)
+	return resp.StatusCode, err
+}
+
+func (c *RestClient) request(ctx context.Context, r *Request) (*http.Request, error) {
+	url, err := r.URL(c.Settings.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload []byte
+	var body io.Reader
+	contentType := "application/json; charset=utf-8"
+	if p, ok := r.Body.(Payload); ok {
+		if contentType, payload, err = p.Encode(); err != nil {
+			return nil, err
 		}
-	} else {
-		var err error
-		req, err = http.NewRequest(method, url, nil)
+		body = bytes.NewBuffer(payload)
+	} else if r.Body != nil {
+		payload, err = json.Marshal(&r.Body)
 		if err != nil {
-			return http.StatusBadRequest, err
+			return nil, err
 		}
+		body = bytes.NewBuffer(payload)
 	}
 
-	return c.roundTrip(req, response)
-}
+	contentEncoding := ""
+	if c.compression != "" && len(payload) >= c.compressionMin && r.Header.Get("Content-Encoding") == "" {
+		if payload, err = compress(c.compression, payload); err != nil {
+			return nil, err
+		}
+		body = bytes.NewBuffer(payload)
+		contentEncoding = c.compression
+	}
 
-func (c *RestClient) roundTrip(req *http.Request, response interface{}) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, r.Method, url, body)
+	if err != nil {
+		return nil, err
+	}
 
-	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Content-Type", contentType)
+	if contentEncoding != "" {
+		req.Header.Set("Content-Encoding", contentEncoding)
+	}
 	req.Header.Set("User-Agent", c.Settings.UserAgent)
 
-	if c.Settings.Credentials.ClientID != "" && c.Settings.Credentials.ClientSecret != "" {
-		req.SetBasicAuth(c.Settings.Credentials.ClientID, c.Settings.Credentials.ClientSecret)
-	} else if c.Settings.Credentials.Token != "" {
-		req.Header.Set("Authorization", "Bearer "+c.Settings.Credentials.Token)
+	// a signed request never contains the secret, it is signed by signingTransport
+	if c.signer == nil {
+		if c.Settings.Credentials.ClientID != "" && c.Settings.Credentials.ClientSecret != "" {
+			req.SetBasicAuth(c.Settings.Credentials.ClientID, c.Settings.Credentials.ClientSecret)
+		} else if c.Settings.Credentials.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.Settings.Credentials.Token)
+		}
+	}
+	if state := callStateFromContext(ctx); state != nil {
+		req.Header.Set("X-Request-ID", state.requestID) // e.g ch3oncmfosvp07shov90
 	}
 	if c.Trace != "" {
-		req.Header.Set("X-Request-ID", XID())    // e.g ch3oncmfosvp07shov90
 		req.Header.Set("X-Force-Trace", c.Trace) // a predefined value in order to e.g. grep in logs
+	} else if trace := ForceTraceFromContext(ctx); trace != "" {
+		req.Header.Set("X-Force-Trace", trace)
+	}
+
+	// per-request headers take precedence over the defaults
+	for k, v := range r.Header {
+		req.Header[k] = v
+	}
+	if state := callStateFromContext(ctx); state != nil && r.Header.Get("X-Request-ID") != "" {
+		state.requestID = r.Header.Get("X-Request-ID")
 	}
 
+	return req, nil
+}
+
+func (c *RestClient) roundTrip(req *http.Request, response interface{}) (*http.Response, error) {
+
 	// perform the request
 	resp, err := c.HttpClient.Transport.RoundTrip(req)
 	if err != nil {
-		if resp == nil {
-			return http.StatusInternalServerError, err
-		}
-		return resp.StatusCode, err
+		return resp, err
 	}
 
 	defer func() { _ = resp.Body.Close() }()
@@ -154,53 +289,84 @@ func (c *RestClient) roundTrip(req *http.Request, response interface{}) (int, er
 	if resp.StatusCode > http.StatusNoContent {
 		body, err := io.ReadAll(resp.Body)
 		if err != nil {
-			return resp.StatusCode, ErrApiInvocationError
+			return resp, &Error{StatusCode: resp.StatusCode, Err: ErrApiInvocationError}
 		}
-		return resp.StatusCode, errors.New(string(body))
+		return resp, &Error{StatusCode: resp.StatusCode, Message: string(body)}
 	}
 
 	// unmarshal the response if one is expected
-	if response != nil {
+	if response != nil && req.Method != http.MethodHead && resp.StatusCode != http.StatusNoContent {
 		err = json.NewDecoder(resp.Body).Decode(response)
 		if err != nil {
-			return http.StatusInternalServerError, err
+			return resp, err
 		}
 	}
 
-	return resp.StatusCode, nil
+	return resp, nil
 }
 
 func NewLoggingTransport(transport http.RoundTripper) *http.Client {
+	return &http.Client{
+		Transport: newLoggingTransport(transport, nil, nil),
+	}
+}
+
+// newLoggingTransport stacks the logging, retry and attempt layers on top of transport
+func newLoggingTransport(transport http.RoundTripper, metrics Metrics, tracer Tracer) *LoggingTransport {
 	retryTransport := rehttp.NewTransport(
-		transport,
+		&attemptTransport{InnerTransport: transport, Metrics: metrics, Tracer: tracer},
 		rehttp.RetryAll(
 			rehttp.RetryMaxRetries(3),
 			rehttp.RetryAny(
 				rehttp.RetryTemporaryErr(),
-				rehttp.RetryStatuses(502, 503),
+				rehttp.RetryStatuses(429, 502, 503),
 			),
 		),
-		rehttp.ExpJitterDelay(100*time.Millisecond, 1*time.Second),
+		retryAfterDelay(rehttp.ExpJitterDelay(100*time.Millisecond, 1*time.Second)),
 	)
 
-	return &http.Client{
-		Transport: &LoggingTransport{
-			InnerTransport: retryTransport,
-		},
+	return &LoggingTransport{
+		InnerTransport: retryTransport,
+		Metrics:        metrics,
+		Tracer:         tracer,
+	}
+}
+
+// retryAfterDelay waits as long as the API asks to via Retry-After, but never less than delay.
+func retryAfterDelay(delay rehttp.DelayFn) rehttp.DelayFn {
+	return func(attempt rehttp.Attempt) time.Duration {
+		d := delay(attempt)
+		if attempt.Response != nil {
+			if ra, ok := RetryAfter(attempt.Response.Header, time.Now()); ok {
+				d = max(d, min(ra, MaxRetryAfter))
+			}
+		}
+		return d
 	}
 }
 
 // RoundTrip logs the request and reply if the log level is debug or trace
 func (t *LoggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 
-	xreqid := XID()
+	xreqid := requestID(req)
+	start := time.Now()
+
+	req, span := startSpan(t.Tracer, req, "HTTP "+req.Method)
 
 	if log.Debug().Enabled() {
-		req = req.WithContext(context.WithValue(req.Context(), ctxKeyRequestStart, time.Now()))
+		req = req.WithContext(context.WithValue(req.Context(), ctxKeyRequestStart, start))
 		t.logRequest(req, xreqid)
 	}
 
 	resp, err := t.InnerTransport.RoundTrip(req)
+	endSpan(span, resp, err)
+
+	if t.Metrics != nil {
+		labels := Labels{"method": req.Method, "route": routeOf(req), "status": statusClass(resp, err)}
+		t.Metrics.IncCounter(MetricRequests, labels)
+		t.Metrics.Observe(MetricRequestDuration, time.Since(start).Seconds(), labels)
+	}
+
 	if err != nil {
 		return resp, err
 	}
@@ -213,9 +379,15 @@ func (t *LoggingTransport) RoundTrip(req *http.Request) (*http.Response, error)
 }
 
 func (t *LoggingTransport) logRequest(req *http.Request, reqid string) {
+	redactor := t.redactor()
+	uri := redactor.URI(req.URL)
 
 	if req.Body == nil {
-		log.Debug().Str("m", req.Method).Str("r", req.URL.RequestURI()).Str("uid", reqid).Msg("REQ")
+		if log.Trace().Enabled() {
+			log.Trace().Str("m", req.Method).Str("r", uri).Interface("h", redactor.Header(req.Header)).Str("uid", reqid).Msg("REQ")
+		} else {
+			log.Debug().Str("m", req.Method).Str("r", uri).Str("uid", reqid).Msg("REQ")
+		}
 		return
 	}
 
@@ -226,10 +398,13 @@ func (t *LoggingTransport) logRequest(req *http.Request, reqid string) {
 	if err != nil {
 		log.Error().Err(err).Str("uid", reqid).Msg(err.Error())
 	} else {
-		if log.Trace().Enabled() {
-			log.Trace().Str("m", req.Method).Str("r", req.URL.RequestURI()).Bytes("body", data).Str("uid", reqid).Msg("REQ")
+		if log.Trace().Enabled() && strings.HasPrefix(req.Header.Get("Content-Type"), "multipart/") {
+			// files are not logged, only their size
+			log.Trace().Str("m", req.Method).Str("r", uri).Interface("h", redactor.Header(req.Header)).Int("size", len(data)).Str("uid", reqid).Msg("REQ")
+		} else if log.Trace().Enabled() {
+			log.Trace().Str("m", req.Method).Str("r", uri).Interface("h", redactor.Header(req.Header)).Bytes("body", redactor.Body(decodeForLog(req.Header, data))).Str("uid", reqid).Msg("REQ")
 		} else {
-			log.Debug().Str("m", req.Method).Str("r", req.URL.RequestURI()).Str("uid", reqid).Msg("REQ")
+			log.Debug().Str("m", req.Method).Str("r", uri).Str("uid", reqid).Msg("REQ")
 		}
 	}
 
@@ -238,6 +413,20 @@ func (t *LoggingTransport) logRequest(req *http.Request, reqid string) {
 
 func (t *LoggingTransport) logResponse(resp *http.Response, reqid string) {
 	ctx := resp.Request.Context()
+	redactor := t.redactor()
+	uri := redactor.URI(resp.Request.URL)
+
+	// a stream is consumed while it arrives, buffering it would block until the server closes it.
+	// Downloads are not buffered either, they may be larger than the memory.
+	if isStream(resp.Header) || isOpened(resp.Request) {
+		if log.Trace().Enabled() {
+			log.Trace().Str("r", uri).Int("status", resp.StatusCode).Interface("h", redactor.Header(resp.Header)).Bool("stream", true).Str("uid", reqid).Msg("RESP")
+		} else {
+			log.Debug().Str("r", uri).Int("status", resp.StatusCode).Bool("stream", true).Str("uid", reqid).Msg("RESP")
+		}
+		return
+	}
+
 	defer func() { _ = resp.Body.Close() }()
 
 	data, err := io.ReadAll(resp.Body)
@@ -247,21 +436,45 @@ func (t *LoggingTransport) logResponse(resp *http.Response, reqid string) {
 
 	if start, ok := ctx.Value(ctxKeyRequestStart).(time.Time); ok {
 		if log.Trace().Enabled() {
-			log.Trace().Str("r", resp.Request.URL.RequestURI()).Int("status", resp.StatusCode).Bytes("body", data).Str("d", (string)(Duration(time.Since(start), 2))).Str("uid", reqid).Msg("RESP")
+			log.Trace().Str("r", uri).Int("status", resp.StatusCode).Interface("h", redactor.Header(resp.Header)).Bytes("body", redactor.Body(decodeForLog(resp.Header, data))).Str("d", Duration(time.Since(start), 2).String()).Str("uid", reqid).Msg("RESP")
 		} else {
-			log.Debug().Str("r", resp.Request.URL.RequestURI()).Int("status", resp.StatusCode).Str("d", (string)(Duration(time.Since(start), 2))).Str("uid", reqid).Msg("RESP")
+			log.Debug().Str("r", uri).Int("status", resp.StatusCode).Str("d", Duration(time.Since(start), 2).String()).Str("uid", reqid).Msg("RESP")
 		}
 	} else {
 		if log.Trace().Enabled() {
-			log.Trace().Str("r", resp.Request.URL.RequestURI()).Int("status", resp.StatusCode).Bytes("body", data).Str("uid", reqid).Msg("RESP")
+			log.Trace().Str("r", uri).Int("status", resp.StatusCode).Interface("h", redactor.Header(resp.Header)).Bytes("body", redactor.Body(decodeForLog(resp.Header, data))).Str("uid", reqid).Msg("RESP")
 		} else {
-			log.Debug().Str("r", resp.Request.URL.RequestURI()).Int("status", resp.StatusCode).Str("uid", reqid).Msg("RESP")
+			log.Debug().Str("r", uri).Int("status", resp.StatusCode).Str("uid", reqid).Msg("RESP")
 		}
 	}
 
 	resp.Body = io.NopCloser(bytes.NewReader(data))
 }
 
+// decodeForLog returns the decoded body if h carries a Content-Encoding, data otherwise.
+func decodeForLog(h http.Header, data []byte) []byte {
+	contentEncoding := h.Get("Content-Encoding")
+	if contentEncoding == "" {
+		return data
+	}
+	r, err := decode(contentEncoding, io.NopCloser(bytes.NewReader(data)))
+	if err != nil {
+		return data
+	}
+	decoded, err := io.ReadAll(r)
+	if err != nil {
+		return data
+	}
+	return decoded
+}
+
+func (t *LoggingTransport) redactor() *Redactor {
+	if t.Redactor == nil {
+		return defaultRedactor
+	}
+	return t.Redactor
+}
+
 func XID() string {
 	return xid.New().String()
 }
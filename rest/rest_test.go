@@ -2,6 +2,8 @@ package rest
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
@@ -58,3 +60,48 @@ func TestNewRestClientWithOptions(t *testing.T) {
 	assert.NotEmpty(t, cl.Settings.Endpoint)
 	assert.Equal(t, "foo.example.com", cl.Settings.Endpoint)
 }
+
+func TestDoRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPatch, r.Method)
+		assert.Equal(t, "/users/42", r.URL.Path)
+		assert.Equal(t, "x y", r.URL.Query().Get("q"))
+		assert.Equal(t, "bar", r.Header.Get("X-Foo"))
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"42"}`))
+	}))
+	defer srv.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL))
+	assert.NoError(t, err)
+
+	r := NewRequest(http.MethodPatch, "/users/{id}").SetParam("id", "42").AddQuery("q", "x y").SetHeader("X-Foo", "bar")
+	resp := map[string]string{}
+	status, err := cl.Do(context.TODO(), r, &resp)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "42", resp["id"])
+}
+
+func TestHeadAndOptions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			_, _ = w.Write([]byte(`{"allow":"GET"}`))
+		}
+	}))
+	defer srv.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL))
+	assert.NoError(t, err)
+
+	status, err := cl.HEAD("/")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+
+	resp := map[string]string{}
+	status, err = cl.OPTIONS("/", &resp)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "GET", resp["allow"])
+}
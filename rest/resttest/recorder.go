@@ -0,0 +1,236 @@
+package resttest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/txsvc/stdlib/v2"
+	"github.com/txsvc/stdlib/v2/settings"
+)
+
+const (
+	ModeReplay Mode = iota // answer requests from the golden file
+	ModeRecord             // pass requests on and capture the interactions
+
+	// Redacted replaces secrets in recorded interactions
+	Redacted = "REDACTED"
+
+	// RESTTEST_RECORD selects ModeRecord in ModeFromEnv if set to true
+	RESTTEST_RECORD = "RESTTEST_RECORD"
+
+	indentChar             = "  "
+	filePerm   os.FileMode = 0644
+)
+
+type (
+	Mode int
+
+	// Interaction is a recorded request and its response. The bodies are kept as sent, e.g. compressed,
+	// and therefore stored base64 encoded.
+	Interaction struct {
+		Method         string      `json:"method"`
+		URL            string      `json:"url"`
+		RequestHeader  http.Header `json:"request_header,omitempty"`
+		RequestBody    []byte      `json:"request_body,omitempty"`
+		Status         int         `json:"status"`
+		ResponseHeader http.Header `json:"response_header,omitempty"`
+		ResponseBody   []byte      `json:"response_body,omitempty"`
+
+		replayed bool
+	}
+
+	// Recorder is a RoundTripper that records interactions with a real API to a golden file,
+	// or replays them from it. Secrets of the credentials never end up in the file.
+	Recorder struct {
+		Transport http.RoundTripper // used in ModeRecord, http.DefaultTransport if nil
+
+		path    string
+		mode    Mode
+		secrets []string
+
+		mu           sync.Mutex
+		interactions []*Interaction
+	}
+)
+
+// ModeFromEnv returns ModeRecord if RESTTEST_RECORD is set to true, ModeReplay otherwise.
+func ModeFromEnv() Mode {
+	if stdlib.GetBool(RESTTEST_RECORD, false) {
+		return ModeRecord
+	}
+	return ModeReplay
+}
+
+// NewRecorder returns a recorder for the golden file at path. In ModeReplay the file is loaded immediately.
+func NewRecorder(path string, mode Mode, cred *settings.Credentials) (*Recorder, error) {
+	r := &Recorder{
+		path: path,
+		mode: mode,
+	}
+
+	if cred != nil {
+		for _, s := range []string{cred.ClientSecret, cred.Token} {
+			if s != "" {
+				r.secrets = append(r.secrets, s)
+			}
+		}
+		if cred.ClientID != "" && cred.ClientSecret != "" {
+			r.secrets = append(r.secrets, base64.StdEncoding.EncodeToString([]byte(cred.ClientID+":"+cred.ClientSecret)))
+		}
+	}
+
+	if mode == ModeReplay {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &r.interactions); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// RoundTrip records or replays the request.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		data, err := io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = data
+	}
+
+	if r.mode == ModeReplay {
+		return r.replay(req, body)
+	}
+	return r.record(req, body)
+}
+
+// Save writes the recorded interactions to the golden file. It does nothing in ModeReplay.
+func (r *Recorder) Save() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+
+	r.mu.Lock()
+	buf, err := json.MarshalIndent(r.interactions, "", indentChar)
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.path), os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, buf, filePerm)
+}
+
+// Interactions returns the recorded or loaded interactions.
+func (r *Recorder) Interactions() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make([]Interaction, len(r.interactions))
+	for i, in := range r.interactions {
+		result[i] = *in
+	}
+	return result
+}
+
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	if body != nil {
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+
+	in := &Interaction{
+		Method:         req.Method,
+		URL:            r.redact(req.URL.String()),
+		RequestHeader:  r.redactHeader(req.Header),
+		RequestBody:    []byte(r.redact(string(body))),
+		Status:         resp.StatusCode,
+		ResponseHeader: r.redactHeader(resp.Header),
+		ResponseBody:   []byte(r.redact(string(data))),
+	}
+
+	r.mu.Lock()
+	r.interactions = append(r.interactions, in)
+	r.mu.Unlock()
+
+	return resp, nil
+}
+
+func (r *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	url := r.redact(req.URL.String())
+	reqBody := []byte(r.redact(string(body)))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// the first interaction not yet replayed wins, identical requests are answered in recorded order
+	for _, in := range r.interactions {
+		if in.replayed || in.Method != req.Method || in.URL != url || !bytes.Equal(in.RequestBody, reqBody) {
+			continue
+		}
+		in.replayed = true
+
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", in.Status, http.StatusText(in.Status)),
+			StatusCode:    in.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        in.ResponseHeader.Clone(),
+			Body:          io.NopCloser(bytes.NewReader(in.ResponseBody)),
+			ContentLength: int64(len(in.ResponseBody)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("no recorded interaction for %s %s", req.Method, url)
+}
+
+func (r *Recorder) redact(s string) string {
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, Redacted)
+	}
+	return s
+}
+
+func (r *Recorder) redactHeader(h http.Header) http.Header {
+	dup := make(http.Header, len(h))
+	for k, values := range h {
+		for _, v := range values {
+			if k == "Authorization" || k == "Cookie" || k == "Set-Cookie" {
+				v = Redacted
+			}
+			dup.Add(k, r.redact(v))
+		}
+	}
+	return dup
+}
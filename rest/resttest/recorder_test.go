@@ -0,0 +1,118 @@
+package resttest
+
+import (
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/txsvc/stdlib/v2/rest"
+	"github.com/txsvc/stdlib/v2/settings"
+)
+
+func TestRecordReplay(t *testing.T) {
+	golden := filepath.Join(t.TempDir(), "testdata", "users.json")
+	cred := &settings.Credentials{ClientID: "client", Token: "secret-token"}
+
+	// record against a live server
+	srv := NewServer(t)
+	srv.Expect("GET", "/users/{id}").Respond(http.StatusOK, map[string]string{"name": "foo", "token": "secret-token"})
+	srv.Expect("POST", "/users").Respond(http.StatusCreated, map[string]string{"id": "2"})
+
+	rec, err := NewRecorder(golden, ModeRecord, cred)
+	assert.NoError(t, err)
+
+	cl, err := rest.NewRestClient(context.TODO(), rest.WithEndpoint(srv.URL), rest.WithToken(cred.ClientID, cred.Token), rest.WithTransport(rec))
+	assert.NoError(t, err)
+
+	resp := map[string]string{}
+	_, err = cl.GET("/users/1", &resp)
+	assert.NoError(t, err)
+	_, err = cl.POST("/users", map[string]string{"name": "bar"}, &resp)
+	assert.NoError(t, err)
+
+	assert.NoError(t, rec.Save())
+	assert.Len(t, rec.Interactions(), 2)
+
+	data, err := os.ReadFile(golden)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "secret-token")
+	assert.Contains(t, string(data), Redacted)
+
+	// replay without a server, using the same endpoint
+	rep, err := NewRecorder(golden, ModeReplay, cred)
+	assert.NoError(t, err)
+
+	cl, err = rest.NewRestClient(context.TODO(), rest.WithEndpoint(srv.URL), rest.WithToken(cred.ClientID, cred.Token), rest.WithTransport(rep))
+	assert.NoError(t, err)
+
+	resp = map[string]string{}
+	status, err := cl.GET("/users/1", &resp)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "foo", resp["name"])
+	assert.Equal(t, Redacted, resp["token"])
+
+	resp = map[string]string{}
+	status, err = cl.POST("/users", map[string]string{"name": "bar"}, &resp)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, status)
+	assert.Equal(t, "2", resp["id"])
+
+	// everything was replayed already
+	_, err = cl.GET("/users/1", &resp)
+	assert.Error(t, err)
+}
+
+func TestRecordReplayCompressed(t *testing.T) {
+	golden := filepath.Join(t.TempDir(), "testdata", "gzip.json")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		_, _ = zw.Write([]byte(`{"name":"compressed"}`))
+		_ = zw.Close()
+	}))
+	defer srv.Close()
+
+	rec, err := NewRecorder(golden, ModeRecord, nil)
+	assert.NoError(t, err)
+	cl, err := rest.NewRestClient(context.TODO(), rest.WithEndpoint(srv.URL), rest.WithAcceptEncoding(rest.EncodingGzip), rest.WithTransport(rec))
+	assert.NoError(t, err)
+
+	resp := map[string]string{}
+	_, err = cl.GET("/users/1", &resp)
+	assert.NoError(t, err)
+	assert.Equal(t, "compressed", resp["name"])
+	assert.NoError(t, rec.Save())
+
+	// the compressed body survives the golden file
+	rep, err := NewRecorder(golden, ModeReplay, nil)
+	assert.NoError(t, err)
+	cl, err = rest.NewRestClient(context.TODO(), rest.WithEndpoint(srv.URL), rest.WithAcceptEncoding(rest.EncodingGzip), rest.WithTransport(rep))
+	assert.NoError(t, err)
+
+	resp = map[string]string{}
+	_, err = cl.GET("/users/1", &resp)
+	assert.NoError(t, err)
+	assert.Equal(t, "compressed", resp["name"])
+}
+
+func TestReplayMissingGoldenFile(t *testing.T) {
+	_, err := NewRecorder("testdata/nonexistent.json", ModeReplay, nil)
+	assert.Error(t, err)
+}
+
+func TestModeFromEnv(t *testing.T) {
+	_ = os.Unsetenv(RESTTEST_RECORD)
+	assert.Equal(t, ModeReplay, ModeFromEnv())
+
+	_ = os.Setenv(RESTTEST_RECORD, "true")
+	defer func() { _ = os.Unsetenv(RESTTEST_RECORD) }()
+	assert.Equal(t, ModeRecord, ModeFromEnv())
+}
@@ -0,0 +1,243 @@
+// Package resttest provides a fake API server and a record/replay transport
+// to test code built on rest.RestClient without a real backend.
+package resttest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type (
+	// Server is a httptest.Server answering requests according to declared routes.
+	// Requests that match no route fail the test, as do routes that were called less than expected.
+	Server struct {
+		*httptest.Server
+
+		t      testing.TB
+		mu     sync.Mutex
+		routes []*Route
+	}
+
+	// Route declares an expected request and the response to it.
+	Route struct {
+		method string
+		path   string // may contain placeholders, e.g. "/users/{id}"
+		query  url.Values
+		header http.Header
+		body   interface{}
+		times  int // 0 = at least once
+
+		status     int
+		respHeader http.Header
+		respBody   interface{}
+
+		srv   *Server
+		calls int
+	}
+)
+
+// NewServer starts a server that is closed and verified when the test ends.
+func NewServer(t testing.TB) *Server {
+	s := &Server{t: t}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+
+	t.Cleanup(func() {
+		s.Close()
+		s.Verify()
+	})
+	return s
+}
+
+// Expect declares a route for method and path, answering with 200 and no body by default.
+func (s *Server) Expect(method, path string) *Route {
+	r := &Route{
+		method:     method,
+		path:       path,
+		query:      make(url.Values),
+		header:     make(http.Header),
+		status:     http.StatusOK,
+		respHeader: make(http.Header),
+		srv:        s,
+	}
+
+	s.mu.Lock()
+	s.routes = append(s.routes, r)
+	s.mu.Unlock()
+
+	return r
+}
+
+// Verify fails the test for every route that was not called as often as expected.
+func (s *Server) Verify() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, r := range s.routes {
+		if r.times == 0 && r.calls == 0 {
+			s.t.Errorf("route %s %s was never called", r.method, r.path)
+		} else if r.times > 0 && r.calls != r.times {
+			s.t.Errorf("route %s %s called %d times, expected %d", r.method, r.path, r.calls, r.times)
+		}
+	}
+}
+
+func (s *Server) serve(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	s.mu.Lock()
+	var route *Route
+	for _, r := range s.routes {
+		if r.matches(req, body) && (r.times == 0 || r.calls < r.times) {
+			route = r
+			route.calls++
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	if route == nil {
+		s.t.Errorf("unexpected request %s %s", req.Method, req.URL.RequestURI())
+		http.Error(w, fmt.Sprintf("unexpected request %s %s", req.Method, req.URL.RequestURI()), http.StatusNotImplemented)
+		return
+	}
+	route.respond(w)
+}
+
+// WithQuery expects the query parameter key to have value.
+func (r *Route) WithQuery(key, value string) *Route {
+	r.query.Add(key, value)
+	return r
+}
+
+// WithHeader expects the request header key to have value.
+func (r *Route) WithHeader(key, value string) *Route {
+	r.header.Add(key, value)
+	return r
+}
+
+// WithJSON expects a request body that is JSON-equal to body.
+func (r *Route) WithJSON(body interface{}) *Route {
+	r.body = body
+	return r
+}
+
+// Times expects exactly n calls of the route. Further requests no longer match.
+func (r *Route) Times(n int) *Route {
+	r.times = n
+	return r
+}
+
+// Respond sets the status and the body, which is sent as JSON unless it is a string or []byte.
+func (r *Route) Respond(status int, body interface{}) *Route {
+	r.status = status
+	r.respBody = body
+	return r
+}
+
+// RespondHeader adds a header to the response.
+func (r *Route) RespondHeader(key, value string) *Route {
+	r.respHeader.Add(key, value)
+	return r
+}
+
+// Calls returns the number of requests the route answered.
+func (r *Route) Calls() int {
+	r.srv.mu.Lock()
+	defer r.srv.mu.Unlock()
+
+	return r.calls
+}
+
+func (r *Route) matches(req *http.Request, body []byte) bool {
+	if req.Method != r.method || !matchPath(r.path, req.URL.Path) {
+		return false
+	}
+
+	q := req.URL.Query()
+	for k, values := range r.query {
+		for _, v := range values {
+			if !slices.Contains(q[k], v) {
+				return false
+			}
+		}
+	}
+	for k, values := range r.header {
+		for _, v := range values {
+			if !slices.Contains(req.Header.Values(k), v) {
+				return false
+			}
+		}
+	}
+
+	if r.body != nil {
+		return jsonEqual(r.body, body)
+	}
+	return true
+}
+
+func (r *Route) respond(w http.ResponseWriter) {
+	for k, values := range r.respHeader {
+		for _, v := range values {
+			w.Header().Add(k, v)
+		}
+	}
+
+	var data []byte
+	switch b := r.respBody.(type) {
+	case nil:
+	case string:
+		data = []byte(b)
+	case []byte:
+		data = b
+	default:
+		data, _ = json.Marshal(b)
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", "application/json")
+		}
+	}
+
+	w.WriteHeader(r.status)
+	_, _ = w.Write(data)
+}
+
+// matchPath matches a path against a template, a placeholder matches exactly one segment.
+func matchPath(template, path string) bool {
+	ts := strings.Split(strings.Trim(template, "/"), "/")
+	ps := strings.Split(strings.Trim(path, "/"), "/")
+	if len(ts) != len(ps) {
+		return false
+	}
+	for i := range ts {
+		if strings.HasPrefix(ts[i], "{") && strings.HasSuffix(ts[i], "}") {
+			continue
+		}
+		if ts[i] != ps[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func jsonEqual(expected interface{}, body []byte) bool {
+	want, err := json.Marshal(expected)
+	if err != nil {
+		return false
+	}
+
+	var a, b interface{}
+	if json.Unmarshal(want, &a) != nil || json.Unmarshal(body, &b) != nil {
+		return false
+	}
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return bytes.Equal(x, y)
+}
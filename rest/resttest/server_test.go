@@ -0,0 +1,83 @@
+package resttest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/txsvc/stdlib/v2/rest"
+)
+
+func TestServer(t *testing.T) {
+	srv := NewServer(t)
+
+	users := srv.Expect("GET", "/users/{id}").
+		WithQuery("fields", "name").
+		WithHeader("X-Foo", "bar").
+		RespondHeader("ETag", `"1"`).
+		Respond(http.StatusOK, map[string]string{"name": "foo"}).
+		Times(2)
+	srv.Expect("POST", "/users").
+		WithJSON(map[string]string{"name": "bar"}).
+		Respond(http.StatusCreated, `{"id":"2"}`)
+
+	cl, err := rest.NewRestClient(context.TODO(), rest.WithEndpoint(srv.URL))
+	assert.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		resp := map[string]string{}
+		meta, err := cl.Call(context.TODO(), rest.NewRequest("GET", "/users/{id}").SetParam("id", "1").AddQuery("fields", "name").SetHeader("X-Foo", "bar"), &resp)
+		assert.NoError(t, err)
+		assert.Equal(t, "foo", resp["name"])
+		assert.Equal(t, `"1"`, meta.Header.Get("ETag"))
+	}
+	assert.Equal(t, 2, users.Calls())
+
+	resp := map[string]string{}
+	status, err := cl.POST("/users", map[string]string{"name": "bar"}, &resp)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, status)
+	assert.Equal(t, "2", resp["id"])
+}
+
+// failingTB captures the failures reported by the server instead of failing the test.
+type failingTB struct {
+	testing.TB
+
+	mu       sync.Mutex
+	failures []string
+}
+
+func (f *failingTB) Errorf(format string, args ...interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.failures = append(f.failures, fmt.Sprintf(format, args...))
+}
+
+func TestServerUnexpectedRequest(t *testing.T) {
+	ft := &failingTB{TB: t}
+	srv := NewServer(ft)
+	srv.Expect("GET", "/a")
+
+	cl, err := rest.NewRestClient(context.TODO(), rest.WithEndpoint(srv.URL))
+	assert.NoError(t, err)
+
+	status, err := cl.GET("/b", nil)
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusNotImplemented, status)
+
+	srv.Verify()
+	assert.Equal(t, []string{"unexpected request GET /b", "route GET /a was never called"}, ft.failures)
+}
+
+func TestMatchPath(t *testing.T) {
+	assert.True(t, matchPath("/users/{id}", "/users/42"))
+	assert.True(t, matchPath("/users", "/users/"))
+	assert.False(t, matchPath("/users/{id}", "/users/42/items"))
+	assert.False(t, matchPath("/users/{id}", "/groups/42"))
+}
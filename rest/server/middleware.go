@@ -0,0 +1,223 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/txsvc/stdlib/v2/rest"
+	"github.com/txsvc/stdlib/v2/settings"
+)
+
+type (
+	// statusWriter records the status of a response, and the beginning of its body if it is logged
+	statusWriter struct {
+		http.ResponseWriter
+		status    int
+		body      *bytes.Buffer
+		truncated bool
+	}
+
+	contextKey struct {
+		name string
+	}
+)
+
+const (
+	// maxLoggedBodySize limits the part of a request or response body that Logging keeps at trace level
+	maxLoggedBodySize = 64 << 10
+)
+
+var (
+	ctxKeyCredentials = &contextKey{"Credentials"}
+	ctxKeyMaxBodySize = &contextKey{"MaxBodySize"}
+
+	// ErrUnauthorized is the reply to a request without valid credentials
+	ErrUnauthorized = &rest.Error{StatusCode: http.StatusUnauthorized, Message: http.StatusText(http.StatusUnauthorized)}
+)
+
+// RequestID takes X-Request-ID and X-Force-Trace of a request, or creates a new request ID, and
+// puts them into the request's context. Calls of a rest.RestClient with that context pass both on.
+// The response carries the request ID, too.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if id == "" {
+			id = rest.XID()
+		}
+		ctx := rest.ContextWithRequestID(r.Context(), id)
+		if trace := r.Header.Get("X-Force-Trace"); trace != "" {
+			ctx = rest.ContextWithForceTrace(ctx, trace)
+		}
+
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// MaxBodySize limits the size of the request bodies read by Decode to n bytes, e.g. for an upload handler.
+func MaxBodySize(n int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKeyMaxBodySize, n)))
+		})
+	}
+}
+
+func maxBodySizeFromContext(ctx context.Context) int64 {
+	if n, ok := ctx.Value(ctxKeyMaxBodySize).(int64); ok && n > 0 {
+		return n
+	}
+	return DefaultMaxBodySize
+}
+
+// Logging logs requests and replies like rest.LoggingTransport does, if the log level is debug or trace.
+// Use it inside RequestID to log with the request ID. At trace level, only the first 64 KiB of a body are logged.
+func Logging(redactor *rest.Redactor) Middleware {
+	if redactor == nil {
+		redactor = rest.DefaultRedactor()
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !log.Debug().Enabled() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+			reqid := rest.RequestIDFromContext(r.Context())
+			uri := redactor.URI(r.URL)
+			trace := log.Trace().Enabled()
+
+			if trace && r.Body != nil && r.Body != http.NoBody {
+				// only the beginning is read, the handler receives it followed by the rest of the body
+				data, err := io.ReadAll(io.LimitReader(r.Body, maxLoggedBodySize+1))
+				if err != nil {
+					log.Error().Err(err).Str("uid", reqid).Msg(err.Error())
+				}
+				r.Body = struct {
+					io.Reader
+					io.Closer
+				}{io.MultiReader(bytes.NewReader(data), r.Body), r.Body}
+				truncated := len(data) > maxLoggedBodySize
+				if truncated {
+					data = data[:maxLoggedBodySize]
+				}
+				log.Trace().Str("m", r.Method).Str("r", uri).Interface("h", redactor.Header(r.Header)).Bytes("body", redactor.Body(data)).Bool("truncated", truncated).Str("uid", reqid).Msg("REQ")
+			} else if trace {
+				log.Trace().Str("m", r.Method).Str("r", uri).Interface("h", redactor.Header(r.Header)).Str("uid", reqid).Msg("REQ")
+			} else {
+				log.Debug().Str("m", r.Method).Str("r", uri).Str("uid", reqid).Msg("REQ")
+			}
+
+			sw := &statusWriter{ResponseWriter: w}
+			if trace {
+				sw.body = &bytes.Buffer{}
+			}
+			next.ServeHTTP(sw, r)
+
+			d := rest.Duration(time.Since(start), 2).String()
+			if trace {
+				log.Trace().Str("r", uri).Int("status", sw.Status()).Interface("h", redactor.Header(w.Header())).Bytes("body", redactor.Body(sw.body.Bytes())).Bool("truncated", sw.truncated).Str("d", d).Str("uid", reqid).Msg("RESP")
+			} else {
+				log.Debug().Str("r", uri).Int("status", sw.Status()).Str("d", d).Str("uid", reqid).Msg("RESP")
+			}
+		})
+	}
+}
+
+// BearerAuth accepts requests with the token of one of creds, as sent by a client configured with rest.WithToken.
+func BearerAuth(creds ...*settings.Credentials) Middleware {
+	return auth(creds, func(r *http.Request, cred *settings.Credentials) bool {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		return ok && cred.Token != "" && equal(token, cred.Token)
+	}, `Bearer`)
+}
+
+// BasicAuth accepts requests with the client ID and secret of one of creds, as sent by a client configured with rest.WithCredentials.
+func BasicAuth(creds ...*settings.Credentials) Middleware {
+	return auth(creds, func(r *http.Request, cred *settings.Credentials) bool {
+		id, secret, ok := r.BasicAuth()
+		// both are compared, in order not to reveal which one is wrong
+		idOK, secretOK := equal(id, cred.ClientID), equal(secret, cred.ClientSecret)
+		return ok && cred.ClientSecret != "" && idOK && secretOK
+	}, `Basic realm="api"`)
+}
+
+// CredentialsFromContext returns the credentials a request was authenticated with, or nil.
+func CredentialsFromContext(ctx context.Context) *settings.Credentials {
+	if cred, ok := ctx.Value(ctxKeyCredentials).(*settings.Credentials); ok {
+		return cred
+	}
+	return nil
+}
+
+// auth replies with 401 to requests that match none of creds. Expired credentials never match.
+func auth(creds []*settings.Credentials, match func(*http.Request, *settings.Credentials) bool, challenge string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, cred := range creds {
+				if cred == nil || cred.Expires < 0 || cred.Expired() {
+					continue
+				}
+				if match(r, cred) {
+					next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKeyCredentials, cred)))
+					return
+				}
+			}
+
+			log.Warn().Str("m", r.Method).Str("r", r.URL.Path).Str("uid", rest.RequestIDFromContext(r.Context())).Msg("unauthorized")
+			w.Header().Set("WWW-Authenticate", challenge)
+			Error(w, r, ErrUnauthorized)
+		})
+	}
+}
+
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.body != nil {
+		n := min(len(p), maxLoggedBodySize-w.body.Len())
+		w.body.Write(p[:n])
+		w.truncated = w.truncated || n < len(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Status returns the status of the response, 200 if the handler did not set one.
+func (w *statusWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Flush supports streaming handlers.
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap gives http.ResponseController access to the underlying writer.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
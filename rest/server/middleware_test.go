@@ -0,0 +1,171 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/txsvc/stdlib/v2"
+	"github.com/txsvc/stdlib/v2/rest"
+	"github.com/txsvc/stdlib/v2/settings"
+)
+
+func TestRequestID(t *testing.T) {
+	// the handler calls another API, which sees the same request ID and trace marker
+	upstream := make(chan http.Header, 1)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstream <- r.Header
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer api.Close()
+
+	cl, err := rest.NewRestClient(context.TODO(), rest.WithEndpoint(api.URL))
+	assert.NoError(t, err)
+	cl.Trace = ""
+
+	h := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := cl.Call(r.Context(), rest.NewRequest(http.MethodGet, "/"), nil)
+		assert.NoError(t, err)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Request-ID", "incoming-id")
+	r.Header.Set("X-Force-Trace", "marker")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, "incoming-id", w.Header().Get("X-Request-ID"))
+
+	seen := <-upstream
+	assert.Equal(t, "incoming-id", seen.Get("X-Request-ID"))
+	assert.Equal(t, "marker", seen.Get("X-Force-Trace"))
+
+	// a new ID without one
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.NotEmpty(t, w.Header().Get("X-Request-ID"))
+	seen = <-upstream
+	assert.Equal(t, w.Header().Get("X-Request-ID"), seen.Get("X-Request-ID"))
+	assert.Empty(t, seen.Get("X-Force-Trace"))
+}
+
+func TestLogging(t *testing.T) {
+	var buf bytes.Buffer
+	logger, level := log.Logger, zerolog.GlobalLevel()
+	log.Logger = zerolog.New(&buf)
+	defer func() {
+		log.Logger = logger
+		zerolog.SetGlobalLevel(level)
+	}()
+
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var u testUser
+		assert.NoError(t, Decode(r, &u)) // the body is still there
+		JSON(w, http.StatusCreated, u)
+	}), RequestID, Logging(nil))
+
+	send := func() {
+		r := httptest.NewRequest(http.MethodPost, "/users?token=secret", strings.NewReader(`{"id":"1","name":"foo"}`))
+		r.Header.Set("X-Request-ID", "log-id")
+		r.Header.Set("Authorization", "Bearer secret")
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	send()
+	out := buf.String()
+	assert.Contains(t, out, `"m":"POST"`)
+	assert.Contains(t, out, `"message":"REQ"`)
+	assert.Contains(t, out, `"status":201`)
+	assert.Contains(t, out, `"message":"RESP"`)
+	assert.Equal(t, 2, strings.Count(out, `"uid":"log-id"`))
+	assert.NotContains(t, out, "secret")
+
+	buf.Reset()
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+	send()
+	out = buf.String()
+	assert.Contains(t, out, `"body":"{\"id\":\"1\",\"name\":\"foo\"}"`)
+	assert.Contains(t, out, `"h":{`)
+	assert.NotContains(t, out, "secret")
+
+	assert.Contains(t, out, `"truncated":false`)
+
+	// large replies are logged in part
+	buf.Reset()
+	large := strings.Repeat("x", 2*maxLoggedBodySize)
+	Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(large[:maxLoggedBodySize-1]))
+		w.Write([]byte(large[maxLoggedBodySize-1:]))
+	}), Logging(nil)).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/large", nil))
+	out = buf.String()
+	assert.Contains(t, out, `"body":"`+large[:maxLoggedBodySize]+`"`)
+	assert.Contains(t, out, `"truncated":true`)
+
+	// large requests are logged in part, the handler receives all of it
+	buf.Reset()
+	Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, large, string(data))
+		w.WriteHeader(http.StatusNoContent)
+	}), Logging(nil)).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/large", strings.NewReader(large)))
+	out = buf.String()
+	assert.Contains(t, out, `"message":"REQ"`)
+	assert.Contains(t, out, `"body":"`+large[:maxLoggedBodySize]+`","truncated":true`)
+
+	buf.Reset()
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	send()
+	assert.Empty(t, buf.String())
+}
+
+func TestAuth(t *testing.T) {
+	token := &settings.Credentials{ClientID: "id", Token: "token"}
+	basic := &settings.Credentials{ClientID: "id", ClientSecret: "secret"}
+	expired := &settings.Credentials{ClientID: "old", Token: "old-token", Expires: stdlib.Now() - 10}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NotNil(t, CredentialsFromContext(r.Context()))
+		w.WriteHeader(http.StatusNoContent)
+	})
+	srv := httptest.NewServer(Chain(ok, RequestID, BearerAuth(expired, token)))
+	defer srv.Close()
+	basicSrv := httptest.NewServer(Chain(ok, RequestID, BasicAuth(basic)))
+	defer basicSrv.Close()
+
+	call := func(url string, opt settings.Option) error {
+		cl, err := rest.NewRestClient(context.TODO(), rest.WithEndpoint(url), opt)
+		assert.NoError(t, err)
+		_, err = cl.Call(context.TODO(), rest.NewRequest(http.MethodGet, "/"), nil)
+		return err
+	}
+	unauthorized := func(err error) bool {
+		var apiErr *rest.Error
+		return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized
+	}
+
+	assert.NoError(t, call(srv.URL, rest.WithToken("id", "token")))
+	assert.True(t, unauthorized(call(srv.URL, rest.WithToken("id", "wrong"))))
+	assert.True(t, unauthorized(call(srv.URL, rest.WithToken("old", "old-token"))))
+	assert.True(t, unauthorized(call(srv.URL, rest.WithCredentials("id", "secret"))))
+
+	assert.NoError(t, call(basicSrv.URL, rest.WithCredentials("id", "secret")))
+	assert.True(t, unauthorized(call(basicSrv.URL, rest.WithCredentials("id", "wrong"))))
+	assert.True(t, unauthorized(call(basicSrv.URL, rest.WithCredentials("other", "secret"))))
+	assert.True(t, unauthorized(call(basicSrv.URL, rest.WithToken("id", "token"))))
+
+	w := httptest.NewRecorder()
+	BasicAuth(basic)(ok).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `Basic realm="api"`, w.Header().Get("WWW-Authenticate"))
+}
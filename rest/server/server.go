@@ -0,0 +1,107 @@
+// Package server provides the server side of the APIs called with rest.RestClient: decoding of JSON
+// requests, error responses the client maps to rest.Error, propagation of X-Request-ID and X-Force-Trace,
+// authentication against settings.Credentials and request logging in the format of rest.LoggingTransport.
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/txsvc/stdlib/v2"
+	"github.com/txsvc/stdlib/v2/rest"
+)
+
+const (
+	// DefaultMaxBodySize limits the size of a request body read by Decode, unless MaxBodySize sets another limit
+	DefaultMaxBodySize = 1 << 20
+
+	contentTypeJSON = "application/json"
+)
+
+type (
+	// Middleware wraps a handler, e.g. with authentication.
+	Middleware func(next http.Handler) http.Handler
+)
+
+// Chain wraps h with mw, the first middleware being the outermost.
+func Chain(h http.Handler, mw ...Middleware) http.Handler {
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	return h
+}
+
+// Decode unmarshals the JSON body of r into v using stdlib.Unmarshal. It returns a *rest.Error
+// with the status to reply with: 415 for a body that is not JSON, 413 for a body exceeding
+// the limit set by MaxBodySize, or DefaultMaxBodySize, and 400 for a body that can not be decoded.
+func Decode(r *http.Request, v interface{}) error {
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		if mt, _, err := mime.ParseMediaType(ct); err != nil || (mt != contentTypeJSON && !strings.HasSuffix(mt, "+json")) {
+			return &rest.Error{StatusCode: http.StatusUnsupportedMediaType, Message: fmt.Sprintf("unsupported content type '%s'", ct), RequestID: rest.RequestIDFromContext(r.Context())}
+		}
+	}
+	if r.Body == nil || r.Body == http.NoBody {
+		return &rest.Error{StatusCode: http.StatusBadRequest, Message: "missing request body", RequestID: rest.RequestIDFromContext(r.Context())}
+	}
+
+	limit := maxBodySizeFromContext(r.Context())
+	data, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		return &rest.Error{StatusCode: http.StatusBadRequest, Err: err, RequestID: rest.RequestIDFromContext(r.Context())}
+	}
+	if int64(len(data)) > limit {
+		return &rest.Error{StatusCode: http.StatusRequestEntityTooLarge, Message: http.StatusText(http.StatusRequestEntityTooLarge), RequestID: rest.RequestIDFromContext(r.Context())}
+	}
+	if err := stdlib.Unmarshal(data, v); err != nil {
+		return &rest.Error{StatusCode: http.StatusBadRequest, Message: "invalid request body", Err: err, RequestID: rest.RequestIDFromContext(r.Context())}
+	}
+	return nil
+}
+
+// JSON replies with status and v encoded as JSON. A nil v replies without a body.
+func JSON(w http.ResponseWriter, status int, v interface{}) {
+	if v == nil {
+		w.WriteHeader(status)
+		return
+	}
+
+	data, err := stdlib.Marshal(v)
+	if err != nil {
+		log.Error().Err(err).Msg("encode response")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
+
+// Error replies with the status and message of err. The client receives them as a *rest.Error with the
+// same StatusCode, Message and RequestID. Errors other than *rest.Error reply with 500, without details.
+func Error(w http.ResponseWriter, r *http.Request, err error) {
+	status := http.StatusInternalServerError
+	msg := http.StatusText(status)
+
+	var apiErr *rest.Error
+	if errors.As(err, &apiErr) {
+		status = apiErr.StatusCode
+		msg = apiErr.Message
+		if msg == "" {
+			msg = http.StatusText(status)
+		}
+	}
+	if status >= http.StatusInternalServerError {
+		log.Error().Err(err).Str("uid", rest.RequestIDFromContext(r.Context())).Msg(err.Error())
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_, _ = io.WriteString(w, msg)
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/txsvc/stdlib/v2/rest"
+)
+
+type testUser struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestDecode(t *testing.T) {
+	var u testUser
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"id":"1","name":"foo"}`))
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+	assert.NoError(t, Decode(r, &u))
+	assert.Equal(t, testUser{ID: "1", Name: "foo"}, u)
+
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"bar"}`))
+	r.Header.Set("Content-Type", "application/merge-patch+json")
+	assert.NoError(t, Decode(r, &u))
+	assert.Equal(t, "bar", u.Name)
+
+	status := func(err error) int {
+		var apiErr *rest.Error
+		if errors.As(err, &apiErr) {
+			return apiErr.StatusCode
+		}
+		return 0
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"id":`))
+	assert.Equal(t, http.StatusBadRequest, status(Decode(r, &u)))
+
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`<xml/>`))
+	r.Header.Set("Content-Type", "text/xml")
+	assert.Equal(t, http.StatusUnsupportedMediaType, status(Decode(r, &u)))
+
+	r = httptest.NewRequest(http.MethodPost, "/", nil)
+	assert.Equal(t, http.StatusBadRequest, status(Decode(r, &u)))
+
+	// the limit is set per handler
+	limited := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Error(w, r, Decode(r, &u))
+	}), MaxBodySize(8))
+	w := httptest.NewRecorder()
+	limited.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"id":"1","name":"foo"}`)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"id":"1","name":"foo"}`))
+	assert.NoError(t, Decode(r, &u))
+}
+
+func TestJSON(t *testing.T) {
+	w := httptest.NewRecorder()
+	JSON(w, http.StatusCreated, testUser{ID: "1"})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"id":"1","name":""}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	JSON(w, http.StatusNoContent, nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Body.String())
+}
+
+func TestErrorRoundTrip(t *testing.T) {
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var u testUser
+		if err := Decode(r, &u); err != nil {
+			Error(w, r, err)
+			return
+		}
+		if u.Name == "" {
+			Error(w, r, &rest.Error{StatusCode: http.StatusUnprocessableEntity, Message: "missing name"})
+			return
+		}
+		if u.Name == "crash" {
+			Error(w, r, errors.New("secret internals"))
+			return
+		}
+		JSON(w, http.StatusOK, u)
+	}), RequestID)
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	cl, err := rest.NewRestClient(context.TODO(), rest.WithEndpoint(srv.URL))
+	assert.NoError(t, err)
+
+	var u testUser
+	_, err = cl.Call(context.TODO(), rest.NewRequest(http.MethodPost, "/users").SetBody(testUser{ID: "1", Name: "foo"}), &u)
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", u.Name)
+
+	// the client sees the error the handler returned, with the same request ID
+	ctx := rest.ContextWithRequestID(context.TODO(), "call-id")
+	_, err = cl.Call(ctx, rest.NewRequest(http.MethodPost, "/users").SetBody(testUser{ID: "1"}), &u)
+	var apiErr *rest.Error
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusUnprocessableEntity, apiErr.StatusCode)
+	assert.Equal(t, "missing name", apiErr.Message)
+	assert.Equal(t, "call-id", apiErr.RequestID)
+
+	// other errors do not leak their details
+	_, err = cl.Call(ctx, rest.NewRequest(http.MethodPost, "/users").SetBody(testUser{ID: "1", Name: "crash"}), &u)
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusInternalServerError, apiErr.StatusCode)
+	assert.Equal(t, http.StatusText(http.StatusInternalServerError), apiErr.Message)
+}
@@ -0,0 +1,309 @@
+package rest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/txsvc/stdlib/v2/settings"
+)
+
+const (
+	// Signing schemes for WithSigning
+	SigningHMAC        = "hmac-sha256" // AWS-SigV4-like signature in the Authorization header
+	SigningHTTPMessage = "rfc9421"     // RFC 9421 HTTP Message Signatures using hmac-sha256
+
+	// HMACAlgorithm is the scheme of the Authorization header written by HMACSigner
+	HMACAlgorithm = "HMAC-SHA256"
+
+	hmacDateFormat = "20060102T150405Z"
+)
+
+type (
+	// Signer signs a request, body is the payload that is sent.
+	Signer interface {
+		Sign(req *http.Request, body []byte) error
+	}
+
+	// HMACSigner signs the canonical form of method, path, query, selected headers and the body digest,
+	// similar to AWS Signature Version 4 but without key derivation.
+	HMACSigner struct {
+		KeyID   string
+		Secret  []byte
+		Headers []string // additional headers to sign
+
+		now func() time.Time
+	}
+
+	// MessageSigner creates RFC 9421 HTTP Message Signatures with the hmac-sha256 algorithm.
+	MessageSigner struct {
+		KeyID      string
+		Secret     []byte
+		Label      string   // "sig1" if empty
+		Components []string // DefaultComponents if empty, missing headers are skipped
+
+		now func() time.Time
+	}
+
+	// signingTransport signs every single attempt, after the endpoint pool chose its URL
+	signingTransport struct {
+		InnerTransport http.RoundTripper
+		signer         Signer
+	}
+)
+
+var (
+	// DefaultComponents are the components covered by a MessageSigner
+	DefaultComponents = []string{"@method", "@authority", "@path", "@query", "content-type", "content-digest", "date"}
+)
+
+// NewSigner returns a signer for scheme that uses the client ID as key id and the client secret as key.
+func NewSigner(scheme string, cred *settings.Credentials) (Signer, error) {
+	if cred == nil || cred.ClientID == "" || cred.ClientSecret == "" {
+		return nil, fmt.Errorf("signing requires client id and secret")
+	}
+
+	switch scheme {
+	case SigningHMAC:
+		return NewHMACSigner(cred.ClientID, cred.ClientSecret), nil
+	case SigningHTTPMessage:
+		return NewMessageSigner(cred.ClientID, cred.ClientSecret), nil
+	}
+	return nil, fmt.Errorf("unsupported signing scheme '%s'", scheme)
+}
+
+// NewHMACSigner returns a HMACSigner.
+func NewHMACSigner(keyID, secret string) *HMACSigner {
+	return &HMACSigner{
+		KeyID:  keyID,
+		Secret: []byte(secret),
+		now:    time.Now,
+	}
+}
+
+// Sign sets the X-Date, X-Content-SHA256 and Authorization headers.
+func (s *HMACSigner) Sign(req *http.Request, body []byte) error {
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+
+	req.Header.Set("X-Date", now().UTC().Format(hmacDateFormat))
+	req.Header.Set("X-Content-SHA256", sha256Hex(body))
+
+	signedHeaders, signature := s.signature(req)
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s, SignedHeaders=%s, Signature=%s", HMACAlgorithm, s.KeyID, signedHeaders, signature))
+	return nil
+}
+
+// Verify checks the signature of a request signed by Sign, e.g. on the receiving side.
+func (s *HMACSigner) Verify(req *http.Request, body []byte) error {
+	auth := strings.TrimPrefix(req.Header.Get("Authorization"), HMACAlgorithm+" ")
+	params := map[string]string{}
+	for _, p := range strings.Split(auth, ",") {
+		if k, v, ok := strings.Cut(strings.TrimSpace(p), "="); ok {
+			params[k] = v
+		}
+	}
+	if params["Credential"] != s.KeyID {
+		return fmt.Errorf("unknown key id '%s'", params["Credential"])
+	}
+	if req.Header.Get("X-Content-SHA256") != sha256Hex(body) {
+		return fmt.Errorf("body digest mismatch")
+	}
+
+	dup := &HMACSigner{KeyID: s.KeyID, Secret: s.Secret, Headers: strings.Split(params["SignedHeaders"], ";")}
+	_, signature := dup.signature(req)
+	if !hmac.Equal([]byte(signature), []byte(params["Signature"])) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+func (s *HMACSigner) signature(req *http.Request) (string, string) {
+	names := map[string]bool{"host": true, "x-date": true, "x-content-sha256": true}
+	if req.Header.Get("Content-Type") != "" {
+		names["content-type"] = true
+	}
+	for _, h := range s.Headers {
+		names[strings.ToLower(h)] = true
+	}
+	signed := make([]string, 0, len(names))
+	for name := range names {
+		signed = append(signed, name)
+	}
+	sort.Strings(signed)
+
+	var headers strings.Builder
+	for _, name := range signed {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = hostOf(req)
+		}
+		headers.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		headers.String(),
+		strings.Join(signed, ";"),
+		req.Header.Get("X-Content-SHA256"),
+	}, "\n")
+
+	toSign := HMACAlgorithm + "\n" + req.Header.Get("X-Date") + "\n" + sha256Hex([]byte(canonical))
+	return strings.Join(signed, ";"), hex.EncodeToString(hmacSHA256(s.Secret, []byte(toSign)))
+}
+
+// NewMessageSigner returns a MessageSigner covering DefaultComponents.
+func NewMessageSigner(keyID, secret string) *MessageSigner {
+	return &MessageSigner{
+		KeyID:  keyID,
+		Secret: []byte(secret),
+		now:    time.Now,
+	}
+}
+
+// Sign sets the Date and Content-Digest headers, if missing, and adds Signature-Input and Signature.
+func (s *MessageSigner) Sign(req *http.Request, body []byte) error {
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	created := now()
+
+	if req.Header.Get("Date") == "" {
+		req.Header.Set("Date", created.UTC().Format(http.TimeFormat))
+	}
+	if len(body) > 0 && req.Header.Get("Content-Digest") == "" {
+		digest := sha256.Sum256(body)
+		req.Header.Set("Content-Digest", "sha-256=:"+base64.StdEncoding.EncodeToString(digest[:])+":")
+	}
+
+	components := s.Components
+	if len(components) == 0 {
+		components = DefaultComponents
+	}
+
+	var base strings.Builder
+	covered := make([]string, 0, len(components))
+	for _, c := range components {
+		value, ok := componentValue(req, c)
+		if !ok {
+			continue
+		}
+		covered = append(covered, strconv.Quote(c))
+		base.WriteString(strconv.Quote(c) + ": " + value + "\n")
+	}
+
+	params := fmt.Sprintf("(%s);created=%d;keyid=%s", strings.Join(covered, " "), created.Unix(), strconv.Quote(s.KeyID))
+	base.WriteString(`"@signature-params": ` + params)
+
+	label := s.Label
+	if label == "" {
+		label = "sig1"
+	}
+	signature := base64.StdEncoding.EncodeToString(hmacSHA256(s.Secret, []byte(base.String())))
+
+	req.Header.Set("Signature-Input", label+"="+params)
+	req.Header.Set("Signature", label+"=:"+signature+":")
+	return nil
+}
+
+// RoundTrip signs a copy of req, the body is read from req.GetBody.
+func (t *signingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return nil, fmt.Errorf("can not sign a request without GetBody")
+		}
+		r, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		body, err = io.ReadAll(r)
+		_ = r.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	signed := req.Clone(req.Context())
+	if err := t.signer.Sign(signed, body); err != nil {
+		return nil, err
+	}
+	return t.InnerTransport.RoundTrip(signed)
+}
+
+// componentValue derives the value of a RFC 9421 component, false if it does not exist.
+func componentValue(req *http.Request, component string) (string, bool) {
+	switch component {
+	case "@method":
+		return req.Method, true
+	case "@authority":
+		return strings.ToLower(hostOf(req)), true
+	case "@path":
+		return req.URL.EscapedPath(), true
+	case "@query":
+		return "?" + req.URL.RawQuery, true
+	case "@target-uri":
+		return req.URL.String(), true
+	}
+
+	// Values returns the request's own storage, the trimmed values go into a copy
+	values := req.Header.Values(component)
+	if len(values) == 0 {
+		return "", false
+	}
+	trimmed := make([]string, len(values))
+	for i, v := range values {
+		trimmed[i] = strings.TrimSpace(v)
+	}
+	return strings.Join(trimmed, ", "), true
+}
+
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := []string{}
+	for _, k := range keys {
+		values := append([]string(nil), q[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, url.QueryEscape(k)+"="+url.QueryEscape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func hostOf(req *http.Request) string {
+	if req.Host != "" {
+		return req.Host
+	}
+	return req.URL.Host
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
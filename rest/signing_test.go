@@ -0,0 +1,149 @@
+package rest
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/txsvc/stdlib/v2/settings"
+)
+
+func TestMessageSignerRFC9421(t *testing.T) {
+	// test vector of RFC 9421, appendix B.2.5
+	key, _ := base64.StdEncoding.DecodeString("uzvJfB4u3N0Jy4T7NZ75MDVcr8zSTInedJtkgcu46YW4XByzNJjxBdtjUkdJPBtbmHhIDi6pcl8jsasjlTMtDQ==")
+	body := []byte(`{"hello": "world"}`)
+
+	req, _ := http.NewRequest("POST", "http://example.com/foo?param=Value&Pet=dog", strings.NewReader(string(body)))
+	req.Header.Set("Date", "Tue, 20 Apr 2021 02:07:55 GMT")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Digest", "sha-512=:WZDPaVn/7XgHaAy8pmojAkGWoRx2UFChF41A2svX+TaPm+AbwAgBWnrIiYllu7BNNyealdVLvRwEmTHWXvJwew==:")
+
+	s := &MessageSigner{
+		KeyID:      "test-shared-secret",
+		Secret:     key,
+		Label:      "sig-b25",
+		Components: []string{"date", "@authority", "content-type"},
+		now:        func() time.Time { return time.Unix(1618884473, 0) },
+	}
+	assert.NoError(t, s.Sign(req, body))
+
+	assert.Equal(t, `sig-b25=("date" "@authority" "content-type");created=1618884473;keyid="test-shared-secret"`, req.Header.Get("Signature-Input"))
+	assert.Equal(t, "sig-b25=:pxcQw6G3AjtMBQjwo8XzkZf/bws5LelbaMk5rGIGtE8=:", req.Header.Get("Signature"))
+}
+
+func TestMessageSignerDefaults(t *testing.T) {
+	req, _ := http.NewRequest("POST", "https://api.example.com/users", nil)
+	req.Header.Set("Content-Type", "application/json")
+
+	assert.NoError(t, NewMessageSigner("id", "secret").Sign(req, []byte(`{}`)))
+
+	assert.NotEmpty(t, req.Header.Get("Date"))
+	assert.Equal(t, "sha-256=:RBNvo1WzZ4oRRq0W9+hknpT7T8If536DEMBg9hyq/4o=:", req.Header.Get("Content-Digest"))
+	assert.True(t, strings.HasPrefix(req.Header.Get("Signature-Input"), `sig1=("@method" "@authority" "@path" "@query" "content-type" "content-digest" "date");created=`))
+	assert.True(t, strings.HasPrefix(req.Header.Get("Signature"), "sig1=:"))
+}
+
+func TestMessageSignerKeepsHeaders(t *testing.T) {
+	req, _ := http.NewRequest("GET", "https://api.example.com/users", nil)
+	req.Header.Add("X-Tags", " a ")
+	req.Header.Add("X-Tags", "b ")
+
+	s := NewMessageSigner("id", "secret")
+	s.Components = []string{"@method", "x-tags"}
+	assert.NoError(t, s.Sign(req, nil))
+	assert.Equal(t, []string{" a ", "b "}, req.Header.Values("X-Tags"))
+}
+
+func TestHMACSigner(t *testing.T) {
+	s := NewHMACSigner("id", "secret")
+	s.now = func() time.Time { return time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC) }
+	s.Headers = []string{"X-Request-ID"}
+
+	body := []byte(`{"a":1}`)
+	req, _ := http.NewRequest("POST", "https://api.example.com/users?b=2&a=1", nil)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Request-ID", "abc")
+
+	assert.NoError(t, s.Sign(req, body))
+	assert.Equal(t, "20261019T120000Z", req.Header.Get("X-Date"))
+	assert.True(t, strings.HasPrefix(req.Header.Get("Authorization"), "HMAC-SHA256 Credential=id, SignedHeaders=content-type;host;x-content-sha256;x-date;x-request-id, Signature="))
+
+	assert.NoError(t, s.Verify(req, body))
+	assert.Error(t, s.Verify(req, []byte(`{"a":2}`)))
+	assert.Error(t, NewHMACSigner("id", "other").Verify(req, body))
+
+	req.Header.Set("X-Request-ID", "xyz")
+	assert.Error(t, s.Verify(req, body))
+}
+
+func TestNewSigner(t *testing.T) {
+	cred := &settings.Credentials{ClientID: "id", ClientSecret: "secret"}
+
+	s, err := NewSigner(SigningHMAC, cred)
+	assert.NoError(t, err)
+	assert.IsType(t, &HMACSigner{}, s)
+
+	s, err = NewSigner(SigningHTTPMessage, cred)
+	assert.NoError(t, err)
+	assert.IsType(t, &MessageSigner{}, s)
+
+	_, err = NewSigner("foo", cred)
+	assert.Error(t, err)
+
+	_, err = NewSigner(SigningHMAC, &settings.Credentials{ClientID: "id", Token: "token"})
+	assert.Error(t, err)
+}
+
+func TestWithSigning(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		// the secret itself is never sent
+		assert.False(t, strings.HasPrefix(r.Header.Get("Authorization"), "Basic"))
+		assert.NoError(t, NewHMACSigner("id", "secret").Verify(r, body))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL), WithCredentials("id", "secret"), WithSigning(SigningHMAC))
+	assert.NoError(t, err)
+	assert.Equal(t, SigningHMAC, cl.Settings.GetOption(OptionSigning))
+
+	_, err = cl.POST("/users?x=1", map[string]string{"name": "foo"}, nil)
+	assert.NoError(t, err)
+
+	// signing without a secret, or with an unknown scheme, fails when the client is created
+	_, err = NewRestClient(context.TODO(), WithEndpoint(srv.URL), WithToken("id", "token"), WithSigning(SigningHTTPMessage))
+	assert.Error(t, err)
+	_, err = NewRestClient(context.TODO(), WithEndpoint(srv.URL), WithCredentials("id", "secret"), WithSigning("foo"))
+	assert.Error(t, err)
+}
+
+func TestSigningWithFailover(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	var verified bool
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, "/b/users", r.URL.Path)
+		verified = assert.NoError(t, NewHMACSigner("id", "secret").Verify(r, body))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer healthy.Close()
+
+	// the signature covers the host and path of the endpoint that receives the request
+	cl, err := NewRestClient(context.TODO(), WithEndpoints(SelectFailover, down.URL+"/a", healthy.URL+"/b"), WithCredentials("id", "secret"), WithSigning(SigningHMAC))
+	assert.NoError(t, err)
+
+	_, err = cl.PUT("/users", map[string]string{"name": "foo"}, nil)
+	assert.NoError(t, err)
+	assert.True(t, verified)
+}
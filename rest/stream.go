@@ -0,0 +1,342 @@
+package rest
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"iter"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	ContentTypeEventStream = "text/event-stream"
+	ContentTypeNDJSON      = "application/x-ndjson"
+
+	// DefaultRetryDelay is the reconnection delay until the server sends a retry hint
+	DefaultRetryDelay = 3 * time.Second
+	// DefaultMaxReconnects limits the reconnects in a row without receiving an event
+	DefaultMaxReconnects = 5
+)
+
+type (
+	// Event is a single Server-Sent Event.
+	Event struct {
+		ID   string
+		Type string // "message" unless the server named the event
+		Data []byte
+	}
+
+	// StreamOptions controls how a stream is consumed.
+	StreamOptions struct {
+		LastEventID      string        // resumes an event stream after this event
+		RetryDelay       time.Duration // DefaultRetryDelay if not set, the server's retry hint takes precedence
+		MaxReconnects    int           // DefaultMaxReconnects if not set, negative disables reconnecting
+		HeartbeatTimeout time.Duration // reconnects if nothing, not even a heartbeat, arrives in time. 0 disables
+	}
+
+	// streamConn is an open stream whose reads are guarded by the heartbeat timeout
+	streamConn struct {
+		body     io.ReadCloser
+		reader   *bufio.Reader
+		timer    *time.Timer
+		timeout  time.Duration
+		timedOut atomic.Bool
+		cancel   context.CancelFunc
+	}
+)
+
+var (
+	// ErrHeartbeatTimeout indicates that a stream was silent for longer than StreamOptions.HeartbeatTimeout
+	ErrHeartbeatTimeout = errors.New("stream heartbeat timeout")
+)
+
+// Decode unmarshals the event's JSON data into v.
+func (e *Event) Decode(v interface{}) error {
+	return json.Unmarshal(e.Data, v)
+}
+
+// Events opens a text/event-stream and yields its events. A dropped connection is re-established
+// with Last-Event-ID after the retry delay. Iteration stops when the server answers 204 No Content,
+// at the first error response, when ctx is done or when reconnecting fails; errors are yielded with a nil event.
+// The client's overall timeout does not apply to streams.
+func (c *RestClient) Events(ctx context.Context, r *Request, opts StreamOptions) iter.Seq2[*Event, error] {
+	delay := opts.RetryDelay
+	if delay <= 0 {
+		delay = DefaultRetryDelay
+	}
+	maxReconnects := opts.MaxReconnects
+	if maxReconnects == 0 {
+		maxReconnects = DefaultMaxReconnects
+	}
+
+	return func(yield func(*Event, error) bool) {
+		lastID := opts.LastEventID
+
+		for reconnects := 0; ; reconnects++ {
+			req := r.Clone().SetHeader("Accept", ContentTypeEventStream).SetHeader("Cache-Control", "no-cache")
+			if lastID != "" {
+				req.SetHeader("Last-Event-ID", lastID)
+			}
+
+			conn, status, err := c.openStream(ctx, req, opts.HeartbeatTimeout)
+			if status == http.StatusNoContent {
+				conn.close()
+				return // the server asks not to reconnect
+			}
+			if apiErr, ok := err.(*Error); ok {
+				yield(nil, apiErr)
+				return
+			}
+
+			if conn != nil {
+				var ev *Event
+				var retry time.Duration
+				for {
+					ev, retry, err = conn.nextEvent(&lastID)
+					if retry > 0 {
+						delay = retry
+					}
+					if ev == nil {
+						break
+					}
+					reconnects = -1 // counts the reconnects in a row without progress
+					if !yield(ev, nil) {
+						conn.close()
+						return
+					}
+				}
+				conn.close()
+			}
+
+			if ctx.Err() != nil {
+				yield(nil, ctx.Err())
+				return
+			}
+			if maxReconnects < 0 || reconnects >= maxReconnects {
+				if err == nil {
+					err = io.ErrUnexpectedEOF
+				}
+				yield(nil, err)
+				return
+			}
+			log.Debug().Str("r", r.Path).Str("last", lastID).Dur("delay", delay).AnErr("error", err).Msg("RECONNECT")
+
+			select {
+			case <-ctx.Done():
+				yield(nil, ctx.Err())
+				return
+			case <-time.After(delay):
+			}
+		}
+	}
+}
+
+// StreamJSON requests r and yields the values of a newline-delimited JSON response one by one.
+// Empty lines count as heartbeats. Iteration stops at the first error, which is yielded together with the zero value of T.
+// NDJSON has no notion of resuming, a dropped connection therefore ends the iteration with an error.
+func StreamJSON[T any](ctx context.Context, c *RestClient, r *Request, opts StreamOptions) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+
+		req := r.Clone().SetHeader("Accept", ContentTypeNDJSON)
+		conn, _, err := c.openStream(ctx, req, opts.HeartbeatTimeout)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		defer conn.close()
+
+		for {
+			line, err := conn.readLine()
+			if err == io.EOF && line == "" {
+				return
+			}
+			if err != nil && err != io.EOF {
+				if ctx.Err() != nil {
+					err = ctx.Err()
+				}
+				yield(zero, err)
+				return
+			}
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+
+			var v T
+			if err := json.Unmarshal([]byte(line), &v); err != nil {
+				yield(zero, err)
+				return
+			}
+			if !yield(v, nil) {
+				return
+			}
+		}
+	}
+}
+
+// openStream sends the request and returns the open response body. An error response is returned as *Error.
+func (c *RestClient) openStream(ctx context.Context, r *Request, heartbeat time.Duration) (*streamConn, int, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	resp, err := c.open(ctx, r)
+	if err != nil {
+		cancel()
+		if apiErr, ok := err.(*Error); ok {
+			return nil, apiErr.StatusCode, err
+		}
+		return nil, 0, err
+	}
+
+	conn := &streamConn{
+		body:    resp.Body,
+		reader:  bufio.NewReader(resp.Body),
+		timeout: heartbeat,
+		cancel:  cancel,
+	}
+	if heartbeat > 0 {
+		conn.timer = time.AfterFunc(heartbeat, func() {
+			conn.timedOut.Store(true)
+			cancel()
+		})
+	}
+	return conn, resp.StatusCode, nil
+}
+
+// readLine returns the next line without its line ending and resets the heartbeat timer.
+func (s *streamConn) readLine() (string, error) {
+	line, err := s.reader.ReadString('\n')
+	if err != nil && s.timedOut.Load() {
+		return "", ErrHeartbeatTimeout
+	}
+	if s.timer != nil {
+		s.timer.Reset(s.timeout)
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), err
+}
+
+// nextEvent parses the stream up to the next event. lastID keeps the last event ID across events.
+// It returns a nil event at the end of the stream, together with the error that ended it, if any.
+func (s *streamConn) nextEvent(lastID *string) (*Event, time.Duration, error) {
+	var retry time.Duration
+	var data strings.Builder
+	eventType := ""
+	hasData := false
+
+	for {
+		line, err := s.readLine()
+		if err != nil && (err != io.EOF || line == "") {
+			if err == io.EOF {
+				err = nil // the server closed the stream
+			}
+			return nil, retry, err
+		}
+
+		if line == "" {
+			// dispatch the event
+			if !hasData {
+				eventType = ""
+				continue
+			}
+			ev := &Event{ID: *lastID, Type: eventType, Data: []byte(strings.TrimSuffix(data.String(), "\n"))}
+			if ev.Type == "" {
+				ev.Type = "message"
+			}
+			return ev, retry, nil
+		}
+		if strings.HasPrefix(line, ":") {
+			continue // a comment, usually a heartbeat
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch field {
+		case "event":
+			eventType = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				*lastID = value
+			}
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil && ms >= 0 {
+				retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
+
+func (s *streamConn) close() {
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	_ = s.body.Close()
+	s.cancel()
+}
+
+// open sends the request without the attempt timeout and returns the response with its body still open.
+// An error response is returned as *Error. The response is neither buffered for logging nor cached.
+func (c *RestClient) open(ctx context.Context, r *Request) (*http.Response, error) {
+	state := &callState{
+		route:     r.route(),
+		requestID: RequestIDFromContext(ctx),
+		stream:    true,
+	}
+	if state.requestID == "" {
+		state.requestID = XID()
+	}
+
+	req, err := c.request(context.WithValue(ctx, ctxKeyCallState, state), r)
+	if err != nil {
+		return nil, &Error{StatusCode: http.StatusBadRequest, RequestID: state.requestID, Err: err}
+	}
+
+	// the client set by SetClient replaces the stream transport as well
+	transport := c.stream
+	if transport == nil {
+		transport = c.HttpClient.Transport
+	}
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// as in roundTrip, but partial content is expected by Download
+	if resp.StatusCode > http.StatusNoContent && resp.StatusCode != http.StatusPartialContent {
+		defer func() { _ = resp.Body.Close() }()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, &Error{StatusCode: resp.StatusCode, RequestID: state.requestID, Err: ErrApiInvocationError}
+		}
+		return nil, &Error{StatusCode: resp.StatusCode, RequestID: state.requestID, Message: string(body)}
+	}
+	return resp, nil
+}
+
+// isStream reports whether h describes a streamed response that must not be buffered.
+func isStream(h http.Header) bool {
+	mediaType, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	switch mediaType {
+	case ContentTypeEventStream, ContentTypeNDJSON, "application/jsonl", "application/stream+json":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvents(t *testing.T) {
+	var connects atomic.Int32
+	lastIDs := make(chan string, 4)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, ContentTypeEventStream, r.Header.Get("Accept"))
+		lastIDs <- r.Header.Get("Last-Event-ID")
+
+		if connects.Add(1) > 2 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", ContentTypeEventStream)
+		if connects.Load() == 1 {
+			fmt.Fprint(w, "retry: 10\n: heartbeat\n\nid: 1\ndata: {\"n\":1}\n\nid: 2\nevent: progress\ndata: line 1\ndata: line 2\r\n\ndata: incomplete")
+		} else {
+			fmt.Fprint(w, "id: 3\ndata: {\"n\":3}\n\n")
+		}
+	}))
+	defer srv.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL))
+	assert.NoError(t, err)
+
+	var events []*Event
+	for ev, err := range cl.Events(context.TODO(), NewRequest(http.MethodGet, "/events"), StreamOptions{}) {
+		assert.NoError(t, err)
+		events = append(events, ev)
+	}
+
+	if assert.Len(t, events, 3) {
+		assert.Equal(t, &Event{ID: "1", Type: "message", Data: []byte(`{"n":1}`)}, events[0])
+		assert.Equal(t, &Event{ID: "2", Type: "progress", Data: []byte("line 1\nline 2")}, events[1])
+
+		v := struct{ N int }{}
+		assert.NoError(t, events[2].Decode(&v))
+		assert.Equal(t, 3, v.N)
+	}
+	assert.Equal(t, "", <-lastIDs)
+	assert.Equal(t, "2", <-lastIDs)
+	assert.Equal(t, "3", <-lastIDs)
+}
+
+func TestEventsErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			http.Error(w, "not found", http.StatusNotFound)
+		case "/closing":
+			w.Header().Set("Content-Type", ContentTypeEventStream)
+			fmt.Fprint(w, "retry: 1\n\n")
+		case "/silent":
+			w.Header().Set("Content-Type", ContentTypeEventStream)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}
+	}))
+	defer srv.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL))
+	assert.NoError(t, err)
+
+	// error responses are not retried
+	for ev, err := range cl.Events(context.TODO(), NewRequest(http.MethodGet, "/missing"), StreamOptions{}) {
+		assert.Nil(t, ev)
+		var apiErr *Error
+		assert.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	}
+
+	// reconnecting gives up after MaxReconnects
+	n := 0
+	for _, err := range cl.Events(context.TODO(), NewRequest(http.MethodGet, "/closing"), StreamOptions{MaxReconnects: 2}) {
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		n++
+	}
+	assert.Equal(t, 1, n)
+
+	// a silent stream times out
+	for _, err := range cl.Events(context.TODO(), NewRequest(http.MethodGet, "/silent"), StreamOptions{MaxReconnects: -1, HeartbeatTimeout: 50 * time.Millisecond}) {
+		assert.ErrorIs(t, err, ErrHeartbeatTimeout)
+	}
+
+	// cancellation
+	ctx, cancel := context.WithTimeout(context.TODO(), 50*time.Millisecond)
+	defer cancel()
+	for _, err := range cl.Events(ctx, NewRequest(http.MethodGet, "/silent"), StreamOptions{}) {
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	}
+}
+
+func TestStreamJSON(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, ContentTypeNDJSON, r.Header.Get("Accept"))
+		w.Header().Set("Content-Type", ContentTypeNDJSON)
+		fmt.Fprint(w, "{\"n\":1}\n\n{\"n\":2}\n")
+		w.(http.Flusher).Flush()
+
+		// the values arrive before the stream ends, even with the body logged at trace level
+		<-release
+		fmt.Fprint(w, "{\"n\":3}")
+	}))
+	defer srv.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL), WithAttemptTimeout(time.Second))
+	assert.NoError(t, err)
+
+	var values []int
+	for v, err := range StreamJSON[struct{ N int }](context.TODO(), cl, NewRequest(http.MethodGet, "/stream"), StreamOptions{}) {
+		assert.NoError(t, err)
+		values = append(values, v.N)
+		if v.N == 2 {
+			close(release)
+		}
+	}
+	assert.Equal(t, []int{1, 2, 3}, values)
+}
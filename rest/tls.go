@@ -0,0 +1,104 @@
+package rest
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/txsvc/stdlib/v2/settings"
+)
+
+var (
+	// ErrCertificateNotPinned is returned if none of the server's certificates matches a pin
+	ErrCertificateNotPinned = errors.New("server certificate does not match any pin")
+
+	tlsVersions = map[string]uint16{
+		"1.0": tls.VersionTLS10,
+		"1.1": tls.VersionTLS11,
+		"1.2": tls.VersionTLS12,
+		"1.3": tls.VersionTLS13,
+	}
+)
+
+// newTLSConfig returns the TLS configuration selected by the client's options, or nil if there is none.
+func newTLSConfig(ds *settings.DialSettings) (*tls.Config, error) {
+	if !ds.HasOption(OptionTLSCertFile) && !ds.HasOption(OptionTLSRootCAs) && !ds.HasOption(OptionTLSMinVersion) && !ds.HasOption(OptionTLSPins) {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if certFile := ds.GetOption(OptionTLSCertFile); certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, ds.GetOption(OptionTLSKeyFile))
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if caFile := ds.GetOption(OptionTLSRootCAs); caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in '%s'", caFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if v := ds.GetOption(OptionTLSMinVersion); v != "" {
+		version, ok := tlsVersions[v]
+		if !ok {
+			return nil, fmt.Errorf("unsupported TLS version '%s'", v)
+		}
+		cfg.MinVersion = version
+	}
+
+	if pins := ds.GetOption(OptionTLSPins); pins != "" {
+		verify, err := pinVerifier(strings.Split(pins, ","))
+		if err != nil {
+			return nil, err
+		}
+		cfg.VerifyConnection = verify
+	}
+
+	return cfg, nil
+}
+
+// pinVerifier accepts a connection if the SHA-256 of the public key of one of the server's certificates
+// matches a pin. Pins are hex or base64 encoded, as printed by e.g. openssl.
+func pinVerifier(pins []string) (func(tls.ConnectionState) error, error) {
+	accepted := make(map[string]bool, len(pins))
+	for _, pin := range pins {
+		pin = strings.TrimPrefix(strings.TrimSpace(pin), "sha256/")
+
+		digest, err := hex.DecodeString(strings.ReplaceAll(pin, ":", ""))
+		if err != nil || len(digest) != sha256.Size {
+			digest, err = base64.StdEncoding.DecodeString(pin)
+			if err != nil || len(digest) != sha256.Size {
+				return nil, fmt.Errorf("invalid certificate pin '%s'", pin)
+			}
+		}
+		accepted[string(digest)] = true
+	}
+
+	return func(cs tls.ConnectionState) error {
+		for _, cert := range cs.PeerCertificates {
+			digest := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+			if accepted[string(digest[:])] {
+				return nil
+			}
+		}
+		return ErrCertificateNotPinned
+	}, nil
+}
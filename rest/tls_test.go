@@ -0,0 +1,127 @@
+package rest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeClientCertificate creates a self-signed client certificate and returns the paths of cert and key.
+func writeClientCertificate(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client.key")
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return certFile, keyFile
+}
+
+func newTLSServer(t *testing.T) (*httptest.Server, string) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"cn":"` + r.TLS.PeerCertificates[0].Subject.CommonName + `"}`))
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	srv.StartTLS()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	assert.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0600))
+	return srv, caFile
+}
+
+func TestMutualTLS(t *testing.T) {
+	srv, caFile := newTLSServer(t)
+	defer srv.Close()
+
+	certFile, keyFile := writeClientCertificate(t, t.TempDir())
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL), WithRootCAs(caFile), WithClientCertificate(certFile, keyFile), WithTLSMinVersion(tls.VersionTLS13))
+	assert.NoError(t, err)
+	assert.Equal(t, "1.3", cl.Settings.GetOption(OptionTLSMinVersion))
+
+	// an unknown version is not ignored
+	_, err = NewRestClient(context.TODO(), WithEndpoint(srv.URL), WithTLSMinVersion(0))
+	assert.Error(t, err)
+
+	resp := map[string]string{}
+	status, err := cl.GET("/", &resp)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "client", resp["cn"])
+
+	// no client certificate
+	cl, err = NewRestClient(context.TODO(), WithEndpoint(srv.URL), WithRootCAs(caFile))
+	assert.NoError(t, err)
+	status, err = cl.GET("/", nil)
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	// the server's certificate is unknown to the system's CAs
+	cl, err = NewRestClient(context.TODO(), WithEndpoint(srv.URL))
+	assert.NoError(t, err)
+	_, err = cl.GET("/", nil)
+	assert.Error(t, err)
+}
+
+func TestCertificatePinning(t *testing.T) {
+	srv, caFile := newTLSServer(t)
+	defer srv.Close()
+
+	certFile, keyFile := writeClientCertificate(t, t.TempDir())
+	digest := sha256.Sum256(srv.Certificate().RawSubjectPublicKeyInfo)
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL), WithRootCAs(caFile), WithClientCertificate(certFile, keyFile), WithCertificatePinning(hex.EncodeToString(digest[:])))
+	assert.NoError(t, err)
+	_, err = cl.GET("/", nil)
+	assert.NoError(t, err)
+
+	other := sha256.Sum256([]byte("other"))
+	cl, err = NewRestClient(context.TODO(), WithEndpoint(srv.URL), WithRootCAs(caFile), WithCertificatePinning(hex.EncodeToString(other[:])))
+	assert.NoError(t, err)
+	_, err = cl.GET("/", nil)
+	assert.ErrorIs(t, err, ErrCertificateNotPinned)
+}
+
+func TestInvalidTLSOptions(t *testing.T) {
+	_, err := NewRestClient(context.TODO(), WithEndpoint("https://example.com"), WithRootCAs("nonexistent.pem"))
+	assert.Error(t, err)
+
+	_, err = NewRestClient(context.TODO(), WithEndpoint("https://example.com"), WithClientCertificate("nonexistent.pem", "nonexistent.key"))
+	assert.Error(t, err)
+
+	_, err = NewRestClient(context.TODO(), WithEndpoint("https://example.com"), WithCertificatePinning("not a pin"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,265 @@
+package rest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// W3C Trace Context headers
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+
+	// FlagSampled is the sampled flag of the traceparent header
+	FlagSampled byte = 0x01
+)
+
+type (
+	// SpanContext identifies a span across process boundaries, see https://www.w3.org/TR/trace-context/
+	SpanContext struct {
+		TraceID    [16]byte
+		SpanID     [8]byte
+		Flags      byte
+		TraceState string
+	}
+
+	// Span is a unit of work, i.e. a call or one of its attempts.
+	Span interface {
+		SpanContext() SpanContext
+		SetAttribute(key string, value interface{})
+		SetError(err error)
+		End()
+	}
+
+	// Tracer starts spans as children of the span in ctx. Adapt it to OpenTelemetry
+	// or use MemoryTracer in tests. The returned context must carry the new span.
+	Tracer interface {
+		Start(ctx context.Context, name string) (context.Context, Span)
+	}
+
+	// MemoryTracer keeps all spans in memory.
+	MemoryTracer struct {
+		mu    sync.Mutex
+		spans []*MemorySpan
+	}
+
+	// MemorySpan is a span recorded by MemoryTracer.
+	MemorySpan struct {
+		Name       string
+		Parent     SpanContext
+		Context    SpanContext
+		Attributes map[string]interface{}
+		Err        error
+		Start      time.Time
+		Finish     time.Time
+
+		mu sync.Mutex
+	}
+)
+
+var (
+	ctxKeySpanContext = &contextKey{"SpanContext"}
+)
+
+// ContextWithSpanContext returns a context carrying sc, e.g. extracted from an incoming request.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, ctxKeySpanContext, sc)
+}
+
+// SpanContextFromContext returns the span context in ctx, if any.
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(ctxKeySpanContext).(SpanContext)
+	return sc, ok && sc.IsValid()
+}
+
+// SpanContextFromHeader extracts the span context from the traceparent and tracestate headers.
+func SpanContextFromHeader(h http.Header) (SpanContext, bool) {
+	sc, err := ParseTraceparent(h.Get(TraceparentHeader))
+	if err != nil {
+		return SpanContext{}, false
+	}
+	sc.TraceState = h.Get(TracestateHeader)
+	return sc, true
+}
+
+// ParseTraceparent parses a traceparent header value like "00-<trace id>-<span id>-<flags>".
+func ParseTraceparent(value string) (SpanContext, error) {
+	sc := SpanContext{}
+
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return sc, fmt.Errorf("invalid traceparent '%s'", value)
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, fmt.Errorf("invalid traceparent '%s'", value)
+	}
+
+	var flags [1]byte
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, fmt.Errorf("invalid trace id '%s'", parts[1])
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, fmt.Errorf("invalid span id '%s'", parts[2])
+	}
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return sc, fmt.Errorf("invalid trace flags '%s'", parts[3])
+	}
+	sc.Flags = flags[0]
+
+	if !sc.IsValid() {
+		return sc, fmt.Errorf("invalid traceparent '%s'", value)
+	}
+	return sc, nil
+}
+
+// IsValid reports whether neither trace nor span ID are all zeros.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// Traceparent returns the traceparent header value of sc.
+func (sc SpanContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", hex.EncodeToString(sc.TraceID[:]), hex.EncodeToString(sc.SpanID[:]), sc.Flags)
+}
+
+// Inject sets the traceparent and tracestate headers.
+func (sc SpanContext) Inject(h http.Header) {
+	h.Set(TraceparentHeader, sc.Traceparent())
+	if sc.TraceState != "" {
+		h.Set(TracestateHeader, sc.TraceState)
+	} else {
+		h.Del(TracestateHeader)
+	}
+}
+
+// NewMemoryTracer returns a tracer that records spans in memory.
+func NewMemoryTracer() *MemoryTracer {
+	return &MemoryTracer{}
+}
+
+// Start starts a span as child of the span in ctx, or a new trace if there is none.
+func (t *MemoryTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	parent, ok := SpanContextFromContext(ctx)
+
+	span := &MemorySpan{
+		Name:       name,
+		Attributes: make(map[string]interface{}),
+		Start:      time.Now(),
+	}
+	span.Context.Flags = FlagSampled
+	if ok {
+		span.Parent = parent
+		span.Context.TraceID = parent.TraceID
+		span.Context.Flags = parent.Flags
+		span.Context.TraceState = parent.TraceState
+	} else {
+		_, _ = rand.Read(span.Context.TraceID[:])
+	}
+	_, _ = rand.Read(span.Context.SpanID[:])
+
+	t.mu.Lock()
+	t.spans = append(t.spans, span)
+	t.mu.Unlock()
+
+	return ContextWithSpanContext(ctx, span.Context), span
+}
+
+// Spans returns all spans started so far.
+func (t *MemoryTracer) Spans() []*MemorySpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]*MemorySpan(nil), t.spans...)
+}
+
+// Reset drops all recorded spans.
+func (t *MemoryTracer) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.spans = nil
+}
+
+func (s *MemorySpan) SpanContext() SpanContext {
+	return s.Context
+}
+
+func (s *MemorySpan) SetAttribute(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Attributes[key] = value
+}
+
+func (s *MemorySpan) SetError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Err = err
+}
+
+func (s *MemorySpan) End() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Finish.IsZero() {
+		s.Finish = time.Now()
+	}
+}
+
+// Ended reports whether End was called.
+func (s *MemorySpan) Ended() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return !s.Finish.IsZero()
+}
+
+// startSpan starts a span if there is a tracer and returns the request with the span's context.
+func startSpan(tracer Tracer, req *http.Request, name string) (*http.Request, Span) {
+	if tracer == nil {
+		return req, nil
+	}
+
+	ctx, span := tracer.Start(req.Context(), name)
+	span.SetAttribute("http.method", req.Method)
+	span.SetAttribute("http.route", routeOf(req))
+	span.SetAttribute("request.id", requestID(req))
+	return req.WithContext(ctx), span
+}
+
+// endSpan records the outcome of a request and ends the span.
+func endSpan(span Span, resp *http.Response, err error) {
+	if span == nil {
+		return
+	}
+
+	if err != nil {
+		span.SetError(err)
+	} else {
+		span.SetAttribute("http.status_code", resp.StatusCode)
+		if resp.StatusCode >= http.StatusInternalServerError {
+			span.SetError(fmt.Errorf(MsgStatus, "server error", resp.StatusCode))
+		}
+	}
+	span.End()
+}
+
+// propagate returns a shallow copy of req with the trace headers of the span in its context, if any.
+func propagate(req *http.Request) *http.Request {
+	sc, ok := SpanContextFromContext(req.Context())
+	if !ok {
+		return req
+	}
+
+	dup := req.WithContext(req.Context())
+	dup.Header = req.Header.Clone()
+	sc.Inject(dup.Header)
+	return dup
+}
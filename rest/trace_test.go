@@ -0,0 +1,114 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestParseTraceparent(t *testing.T) {
+	sc, err := ParseTraceparent(testTraceparent)
+	assert.NoError(t, err)
+	assert.True(t, sc.IsValid())
+	assert.Equal(t, FlagSampled, sc.Flags)
+	assert.Equal(t, testTraceparent, sc.Traceparent())
+
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473x-00f067aa0ba902b7-01",
+	} {
+		_, err := ParseTraceparent(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestSpanContextFromHeader(t *testing.T) {
+	h := http.Header{}
+	h.Set(TraceparentHeader, testTraceparent)
+	h.Set(TracestateHeader, "foo=bar")
+
+	sc, ok := SpanContextFromHeader(h)
+	assert.True(t, ok)
+	assert.Equal(t, "foo=bar", sc.TraceState)
+
+	dup := http.Header{}
+	sc.Inject(dup)
+	assert.Equal(t, h, dup)
+}
+
+func TestTracing(t *testing.T) {
+	var calls atomic.Int32
+	headers := make(chan http.Header, 2)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Clone()
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	tracer := NewMemoryTracer()
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL), WithTracer(tracer))
+	assert.NoError(t, err)
+
+	h := http.Header{}
+	h.Set(TraceparentHeader, testTraceparent)
+	h.Set(TracestateHeader, "foo=bar")
+	incoming, _ := SpanContextFromHeader(h)
+
+	_, err = cl.Do(ContextWithSpanContext(context.TODO(), incoming), NewRequest("GET", "/users/{id}").SetParam("id", "1"), nil)
+	assert.NoError(t, err)
+
+	spans := tracer.Spans()
+	assert.Len(t, spans, 3)
+
+	call := spans[0]
+	assert.Equal(t, "HTTP GET", call.Name)
+	assert.Equal(t, incoming, call.Parent)
+	assert.Equal(t, "/users/{id}", call.Attributes["http.route"])
+	assert.Equal(t, http.StatusNoContent, call.Attributes["http.status_code"])
+	assert.True(t, call.Ended())
+
+	for i, attempt := range spans[1:] {
+		assert.Equal(t, "attempt", attempt.Name)
+		assert.Equal(t, call.Context, attempt.Parent)
+		assert.Equal(t, incoming.TraceID, attempt.Context.TraceID)
+		assert.Equal(t, i+1, attempt.Attributes["attempt"])
+		assert.True(t, attempt.Ended())
+
+		// every attempt is propagated with its own span id
+		sent := <-headers
+		assert.Equal(t, attempt.Context.Traceparent(), sent.Get(TraceparentHeader))
+		assert.Equal(t, "foo=bar", sent.Get(TracestateHeader))
+	}
+	assert.Error(t, spans[1].Err)
+	assert.NoError(t, spans[2].Err)
+}
+
+func TestPropagationWithoutTracer(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, testTraceparent, r.Header.Get(TraceparentHeader))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL))
+	assert.NoError(t, err)
+
+	sc, _ := ParseTraceparent(testTraceparent)
+	_, err = cl.Do(ContextWithSpanContext(context.TODO(), sc), NewRequest("GET", "/"), nil)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,472 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/txsvc/stdlib/v2"
+)
+
+const (
+	// DefaultMaxResumes limits how often Download resumes an interrupted transfer
+	DefaultMaxResumes = 3
+)
+
+type (
+	// ProgressFunc is called while a body is transferred. total is -1 if the size is unknown.
+	ProgressFunc func(done, total int64)
+
+	// Multipart is a multipart/form-data payload. The files are read when the request is sent first.
+	Multipart struct {
+		Fields map[string]string
+		Files  []File
+	}
+
+	// File is a file part of a multipart/form-data payload.
+	File struct {
+		Field       string
+		Name        string
+		ContentType string // application/octet-stream if not set
+		Content     io.Reader
+	}
+
+	// DownloadOptions controls how Download transfers and verifies the content.
+	DownloadOptions struct {
+		Checksum    string // the expected stdlib.Checksum of the content, if any
+		Fingerprint string // the expected stdlib.Fingerprint of the content, if any
+		MaxResumes  int    // DefaultMaxResumes if not set, negative disables resuming
+	}
+
+	// download writes a response body to w and resumes it after an interruption
+	download struct {
+		c       *RestClient
+		opts    DownloadOptions
+		w       io.Writer
+		rewind  func() error // restarts w from scratch, nil if w can't be rewound
+		written int64
+		total   int64
+
+		validator string             // the ETag or Last-Modified the written content belongs to
+		keep      func(string) error // persists a new validator, if set
+
+		checksum    hash.Hash32
+		fingerprint hash.Hash
+	}
+
+	progressReader struct {
+		io.ReadCloser
+		done     int64
+		total    int64
+		progress ProgressFunc
+	}
+
+	progressWriter struct {
+		w        io.Writer
+		done     int64
+		total    int64
+		progress ProgressFunc
+	}
+)
+
+var (
+	// ErrChecksumMismatch indicates that downloaded content does not match the expected checksum or fingerprint
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	// ErrResumeNotSupported indicates that an interrupted download can't be resumed
+	ErrResumeNotSupported = errors.New("resume not supported")
+
+	ctxKeyProgress = &contextKey{"Progress"}
+)
+
+// ContextWithProgress returns a context that reports the progress of request bodies sent,
+// e.g. by UploadFile, and of content received by Download.
+func ContextWithProgress(ctx context.Context, progress ProgressFunc) context.Context {
+	return context.WithValue(ctx, ctxKeyProgress, progress)
+}
+
+func progressFromContext(ctx context.Context) ProgressFunc {
+	if progress, ok := ctx.Value(ctxKeyProgress).(ProgressFunc); ok {
+		return progress
+	}
+	return nil
+}
+
+// AddField adds a form field.
+func (m *Multipart) AddField(name, value string) *Multipart {
+	if m.Fields == nil {
+		m.Fields = make(map[string]string)
+	}
+	m.Fields[name] = value
+	return m
+}
+
+// AddFile adds a file with content read from r.
+func (m *Multipart) AddFile(field, name string, r io.Reader) *Multipart {
+	m.Files = append(m.Files, File{Field: field, Name: name, Content: r})
+	return m
+}
+
+// Encode implements Payload. The content of the files is read once and kept in memory, so that
+// a Multipart can be encoded again, e.g. when a clone of its request is sent.
+func (m *Multipart) Encode() (string, []byte, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	names := make([]string, 0, len(m.Fields))
+	for name := range m.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := mw.WriteField(name, m.Fields[name]); err != nil {
+			return "", nil, err
+		}
+	}
+	for i, f := range m.Files {
+		contentType := f.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(f.Field), escapeQuotes(f.Name)))
+		h.Set("Content-Type", contentType)
+
+		part, err := mw.CreatePart(h)
+		if err != nil {
+			return "", nil, err
+		}
+		content, err := io.ReadAll(f.Content)
+		if err != nil {
+			return "", nil, err
+		}
+		m.Files[i].Content = bytes.NewReader(content)
+		if _, err := part.Write(content); err != nil {
+			return "", nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return "", nil, err
+	}
+	return mw.FormDataContentType(), buf.Bytes(), nil
+}
+
+// UploadFile sends the file at path as form field field of a multipart/form-data request r and
+// unmarshals the JSON reply into response. Progress is reported if ctx carries a ProgressFunc.
+// The file is held in memory so that a failed attempt can be retried.
+func (c *RestClient) UploadFile(ctx context.Context, r *Request, field, path string, response interface{}) (*Response, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return &Response{StatusCode: http.StatusBadRequest}, &Error{StatusCode: http.StatusBadRequest, Err: err}
+	}
+	defer f.Close()
+
+	req := r.Clone().SetBody((&Multipart{}).AddFile(field, filepath.Base(path), f))
+	if req.Method == "" {
+		req.Method = http.MethodPost
+	}
+	return c.Call(ctx, req, response)
+}
+
+// Download writes the content returned by r to w. An interrupted transfer is resumed with a Range request,
+// content that does not match the expected checksum or fingerprint fails with ErrChecksumMismatch.
+// Progress is reported if ctx carries a ProgressFunc. If w is an io.WriteSeeker and the server ignores the range,
+// the transfer restarts from scratch. The content is requested with Accept-Encoding identity, ranges count
+// the bytes as stored by the server.
+func (c *RestClient) Download(ctx context.Context, r *Request, w io.Writer, opts DownloadOptions) (*Response, error) {
+	d := &download{c: c, opts: opts, w: w}
+	if ws, ok := w.(io.WriteSeeker); ok {
+		if start, err := ws.Seek(0, io.SeekCurrent); err == nil {
+			d.rewind = func() error {
+				_, err := ws.Seek(start, io.SeekStart)
+				return err
+			}
+		}
+	}
+	return d.run(ctx, r)
+}
+
+// DownloadFile writes the content returned by r to the file at path. The content is written to
+// path + ".part" first, which survives failures and is resumed by the next DownloadFile. The validator
+// of the content is kept in path + ".part.validator", a leftover without one is downloaded again.
+func (c *RestClient) DownloadFile(ctx context.Context, r *Request, path string, opts DownloadOptions) (*Response, error) {
+	part := path + ".part"
+	validator := part + ".validator"
+	f, err := os.OpenFile(part, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return &Response{StatusCode: http.StatusBadRequest}, &Error{StatusCode: http.StatusBadRequest, Err: err}
+	}
+	defer f.Close()
+
+	d := &download{c: c, opts: opts, w: f}
+	d.rewind = func() error {
+		if err := f.Truncate(0); err != nil {
+			return err
+		}
+		_, err := f.Seek(0, io.SeekStart)
+		return err
+	}
+
+	d.keep = func(v string) error {
+		return os.WriteFile(validator, []byte(v), 0644)
+	}
+
+	// continue with what a previous download left, the checksums need to see it too. Without
+	// its validator, If-Range can't tell whether the content changed in the meantime.
+	d.reset()
+	if data, err := os.ReadFile(validator); err == nil && len(data) > 0 {
+		d.validator = string(data)
+		if d.written, err = io.Copy(d.hashes(), f); err != nil {
+			return &Response{StatusCode: http.StatusBadRequest}, &Error{StatusCode: http.StatusBadRequest, Err: err}
+		}
+	} else if err := d.rewind(); err != nil {
+		return &Response{StatusCode: http.StatusBadRequest}, &Error{StatusCode: http.StatusBadRequest, Err: err}
+	}
+
+	resp, err := d.run(ctx, r)
+	if err != nil {
+		if errors.Is(err, ErrChecksumMismatch) {
+			// the next attempt has to start from scratch
+			_ = os.Remove(part)
+			_ = os.Remove(validator)
+		}
+		return resp, err
+	}
+	_ = os.Remove(validator)
+	if err := f.Close(); err != nil {
+		return resp, &Error{StatusCode: resp.StatusCode, RequestID: resp.RequestID, Err: err}
+	}
+	if err := os.Rename(part, path); err != nil {
+		return resp, &Error{StatusCode: resp.StatusCode, RequestID: resp.RequestID, Err: err}
+	}
+	return resp, nil
+}
+
+func (d *download) run(ctx context.Context, r *Request) (*Response, error) {
+	maxResumes := d.opts.MaxResumes
+	if maxResumes == 0 {
+		maxResumes = DefaultMaxResumes
+	}
+	if d.checksum == nil {
+		d.reset()
+	}
+
+	// all attempts of the download share one request ID, as the attempts of Call do
+	id := r.Header.Get("X-Request-ID")
+	if id == "" {
+		id = RequestIDFromContext(ctx)
+	}
+	if id == "" {
+		id = XID()
+	}
+	ctx = ContextWithRequestID(ctx, id)
+
+	start := time.Now()
+	meta := &Response{StatusCode: http.StatusInternalServerError, RequestID: id}
+
+	for resumes := 0; ; resumes++ {
+		req := r.Clone()
+		// Range counts the bytes as sent, decoded content would resume at the wrong offset
+		req.SetHeader("Accept-Encoding", "identity")
+		if d.written > 0 {
+			req.SetHeader("Range", fmt.Sprintf("bytes=%d-", d.written))
+			if d.validator != "" {
+				req.SetHeader("If-Range", d.validator)
+			}
+		}
+
+		meta.Attempts++
+		resp, err := d.c.open(ctx, req)
+		if err == nil {
+			meta.StatusCode = resp.StatusCode
+			meta.Header = resp.Header
+			meta.ServerRequestID = resp.Header.Get("X-Request-ID")
+			// a complete response starts the content over, along with its validator
+			if d.validator == "" || resp.StatusCode != http.StatusPartialContent {
+				err = d.setValidator(resp.Header)
+			}
+			if err == nil {
+				err = d.receive(ctx, resp)
+			} else {
+				_ = resp.Body.Close()
+			}
+		}
+		meta.Duration = time.Since(start)
+
+		if err == nil {
+			return meta, d.verify(meta)
+		}
+		if apiErr, ok := err.(*Error); ok {
+			if apiErr.StatusCode == http.StatusRequestedRangeNotSatisfiable && d.written > 0 && d.rewind != nil {
+				// the partial content is stale, e.g. a larger file than on the server
+				if err := d.restart(); err == nil {
+					continue
+				}
+			}
+			meta.StatusCode = apiErr.StatusCode
+			apiErr.RequestID = meta.RequestID
+			apiErr.ServerRequestID = meta.ServerRequestID
+			return meta, apiErr
+		}
+		if ctx.Err() != nil || errors.Is(err, ErrResumeNotSupported) || maxResumes < 0 || resumes >= maxResumes {
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			}
+			return meta, &Error{StatusCode: meta.StatusCode, RequestID: meta.RequestID, ServerRequestID: meta.ServerRequestID, Err: err}
+		}
+		log.Debug().Str("r", r.Path).Int64("offset", d.written).AnErr("error", err).Str("uid", meta.RequestID).Msg("RESUME")
+
+		select {
+		case <-ctx.Done():
+			return meta, &Error{StatusCode: meta.StatusCode, RequestID: meta.RequestID, ServerRequestID: meta.ServerRequestID, Err: ctx.Err()}
+		case <-time.After(time.Duration(resumes+1) * 100 * time.Millisecond):
+		}
+	}
+}
+
+// receive writes the body of resp, continuing at the current offset if resp is partial content.
+func (d *download) receive(ctx context.Context, resp *http.Response) error {
+	defer func() { _ = resp.Body.Close() }()
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+		offset, total, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || offset != d.written {
+			return fmt.Errorf("%w: unexpected range '%s'", ErrResumeNotSupported, resp.Header.Get("Content-Range"))
+		}
+		d.total = total
+	case d.written > 0:
+		// the server ignored the range and sends everything again
+		if d.rewind == nil {
+			return ErrResumeNotSupported
+		}
+		if err := d.restart(); err != nil {
+			return err
+		}
+		d.total = resp.ContentLength
+	default:
+		d.total = resp.ContentLength
+	}
+
+	w := io.MultiWriter(d.w, d.hashes())
+	if progress := progressFromContext(ctx); progress != nil {
+		w = &progressWriter{w: w, done: d.written, total: d.total, progress: progress}
+	}
+	n, err := io.Copy(w, resp.Body)
+	d.written += n
+	if err != nil {
+		return err
+	}
+	if d.total >= 0 && d.written < d.total {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+func (d *download) verify(meta *Response) error {
+	if d.opts.Checksum != "" && !strings.EqualFold(d.opts.Checksum, hex.EncodeToString(d.checksum.Sum(nil))) {
+		return &Error{StatusCode: meta.StatusCode, RequestID: meta.RequestID, ServerRequestID: meta.ServerRequestID, Err: fmt.Errorf("%w: checksum %x", ErrChecksumMismatch, d.checksum.Sum(nil))}
+	}
+	if d.opts.Fingerprint != "" && !strings.EqualFold(d.opts.Fingerprint, hex.EncodeToString(d.fingerprint.Sum(nil))) {
+		return &Error{StatusCode: meta.StatusCode, RequestID: meta.RequestID, ServerRequestID: meta.ServerRequestID, Err: fmt.Errorf("%w: fingerprint %x", ErrChecksumMismatch, d.fingerprint.Sum(nil))}
+	}
+	return nil
+}
+
+func (d *download) setValidator(h http.Header) error {
+	v := h.Get("ETag")
+	if v == "" {
+		v = h.Get("Last-Modified")
+	}
+	if v == d.validator {
+		return nil
+	}
+	d.validator = v
+	if d.keep != nil {
+		return d.keep(v)
+	}
+	return nil
+}
+
+func (d *download) restart() error {
+	if err := d.rewind(); err != nil {
+		return err
+	}
+	d.written = 0
+	d.reset()
+	return nil
+}
+
+func (d *download) reset() {
+	d.checksum = stdlib.NewChecksum()
+	d.fingerprint = stdlib.NewFingerprint()
+}
+
+func (d *download) hashes() io.Writer {
+	return io.MultiWriter(d.checksum, d.fingerprint)
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.done += int64(n)
+		r.progress(r.done, r.total)
+	}
+	return n, err
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	if n > 0 {
+		w.done += int64(n)
+		w.progress(w.done, w.total)
+	}
+	return n, err
+}
+
+// parseContentRange returns the first byte and the complete length of "bytes first-last/length".
+// The length is -1 if it is unknown.
+func parseContentRange(v string) (int64, int64, bool) {
+	v, ok := strings.CutPrefix(v, "bytes ")
+	if !ok {
+		return 0, 0, false
+	}
+	byteRange, length, ok := strings.Cut(v, "/")
+	if !ok {
+		return 0, 0, false
+	}
+	first, _, ok := strings.Cut(byteRange, "-")
+	if !ok {
+		return 0, 0, false
+	}
+	offset, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	if length == "*" {
+		return offset, -1, true
+	}
+	total, err := strconv.ParseInt(length, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return offset, total, true
+}
+
+func escapeQuotes(s string) string {
+	return strings.NewReplacer("\\", "\\\\", `"`, "\\\"").Replace(s)
+}
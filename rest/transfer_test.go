@@ -0,0 +1,342 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/txsvc/stdlib/v2"
+)
+
+func TestUploadFile(t *testing.T) {
+	content := strings.Repeat("artifact ", 1000)
+	path := filepath.Join(t.TempDir(), "artifact.txt")
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0600))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		f, h, err := r.FormFile("file")
+		if assert.NoError(t, err) {
+			data, _ := io.ReadAll(f)
+			assert.Equal(t, "artifact.txt", h.Filename)
+			assert.Equal(t, content, string(data))
+		}
+		w.Write([]byte(`{"size":9000}`))
+	}))
+	defer srv.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL))
+	assert.NoError(t, err)
+
+	var done, total int64
+	ctx := ContextWithProgress(context.TODO(), func(d, t int64) { done, total = d, t })
+
+	resp := struct{ Size int }{}
+	meta, err := cl.UploadFile(ctx, &Request{Path: "/artifacts"}, "file", path, &resp)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, meta.StatusCode)
+	assert.Equal(t, 9000, resp.Size)
+	assert.Greater(t, total, int64(len(content)))
+	assert.Equal(t, total, done)
+
+	_, err = cl.UploadFile(ctx, &Request{Path: "/artifacts"}, "file", "nonexistent", nil)
+	assert.Error(t, err)
+}
+
+func TestMultipart(t *testing.T) {
+	m := (&Multipart{}).AddField("b", "2").AddField("a", "1").AddFile("file", `a "quoted" name`, strings.NewReader("data"))
+	contentType, data, err := m.Encode()
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(contentType, "multipart/form-data; boundary="))
+	assert.Less(t, bytes.Index(data, []byte(`name="a"`)), bytes.Index(data, []byte(`name="b"`)))
+	assert.Contains(t, string(data), `filename="a \"quoted\" name"`)
+
+	// a clone of the request encodes the files again
+	_, again, err := m.Encode()
+	assert.NoError(t, err)
+	assert.Contains(t, string(again), "\r\n\r\ndata\r\n")
+}
+
+// newDownloadServer serves content and aborts the first response halfway through.
+// With ignoreRange set, the server always sends the complete content.
+func newDownloadServer(content string, ignoreRange bool) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Content-Length", "2000")
+			w.Write([]byte(content[:1000]))
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		if ignoreRange {
+			r.Header.Del("Range")
+		}
+		http.ServeContent(w, r, "report.txt", time.Time{}, strings.NewReader(content))
+	}))
+	return srv, &calls
+}
+
+func TestDownload(t *testing.T) {
+	content := strings.Repeat("0123456789", 200)
+	srv, calls := newDownloadServer(content, false)
+	defer srv.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL))
+	assert.NoError(t, err)
+
+	var done, total int64
+	ctx := ContextWithProgress(context.TODO(), func(d, t int64) { done, total = d, t })
+
+	var buf bytes.Buffer
+	meta, err := cl.Download(ctx, NewRequest(http.MethodGet, "/report"), &buf, DownloadOptions{Checksum: stdlib.Checksum(content), Fingerprint: stdlib.Fingerprint(content)})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusPartialContent, meta.StatusCode)
+	assert.Equal(t, 2, meta.Attempts)
+	assert.Equal(t, int32(2), calls.Load())
+	assert.Equal(t, content, buf.String())
+	assert.Equal(t, int64(2000), done)
+	assert.Equal(t, int64(2000), total)
+
+	// a wrong checksum
+	buf.Reset()
+	_, err = cl.Download(context.TODO(), NewRequest(http.MethodGet, "/report"), &buf, DownloadOptions{Fingerprint: stdlib.Fingerprint("other")})
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+
+	// resuming is disabled
+	calls.Store(0)
+	_, err = cl.Download(context.TODO(), NewRequest(http.MethodGet, "/report"), &buf, DownloadOptions{MaxResumes: -1})
+	assert.Error(t, err)
+}
+
+func TestDownloadSetClient(t *testing.T) {
+	content := strings.Repeat("0123456789", 200)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "report.txt", time.Time{}, strings.NewReader(content))
+	}))
+	defer srv.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL))
+	assert.NoError(t, err)
+
+	var calls atomic.Int32
+	cl.SetClient(&http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		calls.Add(1)
+		return http.DefaultTransport.RoundTrip(req)
+	})})
+
+	var buf bytes.Buffer
+	_, err = cl.Download(context.TODO(), NewRequest(http.MethodGet, "/report"), &buf, DownloadOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, content, buf.String())
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestDownloadRequestID(t *testing.T) {
+	content := strings.Repeat("0123456789", 200)
+	var ids []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ids = append(ids, r.Header.Get("X-Request-ID"))
+		w.Header().Set("X-Request-ID", "server-id")
+		if len(ids) == 1 {
+			w.Header().Set("Content-Length", "2000")
+			w.Write([]byte(content[:1000]))
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "report.txt", time.Time{}, strings.NewReader(content))
+	}))
+	defer srv.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL))
+	assert.NoError(t, err)
+
+	// the resumed download keeps the client's ID, the server's is reported separately
+	var buf bytes.Buffer
+	meta, err := cl.Download(context.TODO(), NewRequest(http.MethodGet, "/report"), &buf, DownloadOptions{Fingerprint: stdlib.Fingerprint("other")})
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+	assert.Len(t, ids, 2)
+	assert.Equal(t, ids[0], ids[1])
+	assert.Equal(t, ids[0], meta.RequestID)
+	assert.Equal(t, "server-id", meta.ServerRequestID)
+
+	var apiErr *Error
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, ids[0], apiErr.RequestID)
+		assert.Equal(t, "server-id", apiErr.ServerRequestID)
+	}
+}
+
+func TestDownloadIdentity(t *testing.T) {
+	content := strings.Repeat("0123456789", 200)
+	var encodings []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encodings = append(encodings, r.Header.Get("Accept-Encoding"))
+		if len(encodings) == 1 {
+			w.Header().Set("Content-Length", "2000")
+			w.Write([]byte(content[:1000]))
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "report.txt", time.Time{}, strings.NewReader(content))
+	}))
+	defer srv.Close()
+
+	// the range counts the bytes as sent, not decoded ones
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL), WithAcceptEncoding(EncodingGzip))
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	_, err = cl.Download(context.TODO(), NewRequest(http.MethodGet, "/report"), &buf, DownloadOptions{Checksum: stdlib.Checksum(content)})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"identity", "identity"}, encodings)
+}
+
+// TestDownloadNotBuffered sends the content only after the client received its first part.
+// Buffering the response, e.g. to log it, would block the download.
+func TestDownloadNotBuffered(t *testing.T) {
+	var buf bytes.Buffer
+	logger, level := log.Logger, zerolog.GlobalLevel()
+	log.Logger = zerolog.New(&buf)
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+	defer func() {
+		log.Logger = logger
+		zerolog.SetGlobalLevel(level)
+	}()
+
+	received := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("first"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-received:
+		case <-time.After(2 * time.Second):
+			return
+		}
+		w.Write([]byte(" second"))
+	}))
+	defer srv.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL), WithCache(NewMemoryCache(1<<20)))
+	assert.NoError(t, err)
+
+	w := &signalWriter{signal: received}
+	_, err = cl.Download(context.TODO(), NewRequest(http.MethodGet, "/report"), w, DownloadOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "first second", w.buf.String())
+	assert.Contains(t, buf.String(), `"stream":true`)
+}
+
+func TestDownloadWithoutRange(t *testing.T) {
+	content := strings.Repeat("0123456789", 200)
+	srv, calls := newDownloadServer(content, true)
+	defer srv.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL))
+	assert.NoError(t, err)
+
+	// a buffer can't start over
+	var buf bytes.Buffer
+	_, err = cl.Download(context.TODO(), NewRequest(http.MethodGet, "/report"), &buf, DownloadOptions{})
+	assert.ErrorIs(t, err, ErrResumeNotSupported)
+
+	// a file can
+	calls.Store(0)
+	path := filepath.Join(t.TempDir(), "report.txt")
+	_, err = cl.DownloadFile(context.TODO(), NewRequest(http.MethodGet, "/report"), path, DownloadOptions{Checksum: stdlib.Checksum(content)})
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, content, string(data))
+}
+
+func TestDownloadFileResume(t *testing.T) {
+	content := strings.Repeat("0123456789", 200)
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range")+r.Header.Get("If-Range"))
+		w.Header().Set("ETag", `"v2"`)
+		http.ServeContent(w, r, "report.txt", time.Time{}, strings.NewReader(content))
+	}))
+	defer srv.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL))
+	assert.NoError(t, err)
+
+	// a previous download was interrupted
+	path := filepath.Join(t.TempDir(), "report.txt")
+	assert.NoError(t, os.WriteFile(path+".part", []byte(content[:500]), 0600))
+	assert.NoError(t, os.WriteFile(path+".part.validator", []byte(`"v2"`), 0600))
+
+	_, err = cl.DownloadFile(context.TODO(), NewRequest(http.MethodGet, "/report"), path, DownloadOptions{Fingerprint: stdlib.Fingerprint(content)})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`bytes=500-"v2"`}, ranges)
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, content, string(data))
+	assert.NoFileExists(t, path+".part")
+	assert.NoFileExists(t, path+".part.validator")
+
+	// the content changed since, the server sends all of it again
+	ranges = nil
+	assert.NoError(t, os.WriteFile(path+".part", []byte("stale"), 0600))
+	assert.NoError(t, os.WriteFile(path+".part.validator", []byte(`"v1"`), 0600))
+
+	_, err = cl.DownloadFile(context.TODO(), NewRequest(http.MethodGet, "/report"), path, DownloadOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`bytes=5-"v1"`}, ranges)
+	data, _ = os.ReadFile(path)
+	assert.Equal(t, content, string(data))
+
+	// a leftover without validator can't be checked, it is downloaded again
+	ranges = nil
+	assert.NoError(t, os.WriteFile(path+".part", []byte("stale"), 0600))
+
+	_, err = cl.DownloadFile(context.TODO(), NewRequest(http.MethodGet, "/report"), path, DownloadOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{""}, ranges)
+	data, _ = os.ReadFile(path)
+	assert.Equal(t, content, string(data))
+}
+
+// signalWriter closes signal on the first write
+type signalWriter struct {
+	buf    bytes.Buffer
+	signal chan struct{}
+}
+
+func (w *signalWriter) Write(p []byte) (int, error) {
+	if w.buf.Len() == 0 {
+		close(w.signal)
+	}
+	return w.buf.Write(p)
+}
+
+func TestParseContentRange(t *testing.T) {
+	offset, total, ok := parseContentRange("bytes 500-1999/2000")
+	assert.True(t, ok)
+	assert.Equal(t, int64(500), offset)
+	assert.Equal(t, int64(2000), total)
+
+	offset, total, ok = parseContentRange("bytes 0-9/*")
+	assert.True(t, ok)
+	assert.Equal(t, int64(0), offset)
+	assert.Equal(t, int64(-1), total)
+
+	_, _, ok = parseContentRange("items 0-9/10")
+	assert.False(t, ok)
+}
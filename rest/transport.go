@@ -0,0 +1,287 @@
+package rest
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/txsvc/stdlib/v2/settings"
+)
+
+// newTransport assembles the layers that are applied to every single attempt of a request,
+// as configured by the client's options. The result is wrapped by the retry and logging layers.
+// An invalid option is an error, as it is for the base transport.
+func (c *RestClient) newTransport(base http.RoundTripper) (http.RoundTripper, error) {
+	ds := c.Settings
+	transport := base
+
+	if v := ds.GetOption(OptionAcceptEncoding); v != "" {
+		transport = &decodingTransport{InnerTransport: transport, acceptEncoding: v}
+	}
+
+	rl := &rateLimitTransport{
+		InnerTransport: transport,
+		limiter:        NewRateLimiter(0, 1),
+		routes:         make(map[string]*RateLimiter),
+	}
+	for k, v := range ds.Options {
+		if k == OptionRateLimit {
+			limiter, err := parseRateLimit(k, v)
+			if err != nil {
+				return nil, err
+			}
+			rl.limiter = limiter
+		} else if route, ok := strings.CutPrefix(k, OptionRateLimit+":"); ok {
+			limiter, err := parseRateLimit(k, v)
+			if err != nil {
+				return nil, err
+			}
+			rl.routes[route] = limiter
+		}
+	}
+	transport = rl
+
+	// each endpoint receives a signature of its own URL
+	if c.signer != nil {
+		transport = &signingTransport{InnerTransport: transport, signer: c.signer}
+	}
+
+	// the breaker sees a failure only if all endpoints failed
+	if v := ds.GetOption(OptionEndpoints); v != "" {
+		pool := NewEndpointPool(transport, strings.Split(v, ","), ds.GetOption(OptionEndpointSelection))
+		d, err := durationOption(ds, OptionHedgeDelay)
+		if err != nil {
+			return nil, err
+		}
+		pool.HedgeDelay = d
+		transport = pool
+	}
+
+	if v := ds.GetOption(OptionCircuitBreaker); v != "" {
+		cb, err := parseCircuitBreaker(transport, v)
+		if err != nil {
+			return nil, err
+		}
+		transport = cb
+	}
+
+	if c.cache != nil {
+		transport = NewCachingTransport(transport, c.cache)
+	}
+
+	return transport, nil
+}
+
+// parseRateLimit parses "rate,burst" as written by WithRateLimit.
+func parseRateLimit(key, value string) (*RateLimiter, error) {
+	r, b, _ := strings.Cut(value, ",")
+
+	rate, err := strconv.ParseFloat(r, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid option %s: '%s'", key, value)
+	}
+	burst := 1
+	if b != "" {
+		if burst, err = strconv.Atoi(b); err != nil {
+			return nil, fmt.Errorf("invalid option %s: '%s'", key, value)
+		}
+	}
+	return NewRateLimiter(rate, burst), nil
+}
+
+// parseCircuitBreaker parses "ratio,window,cooldown" as written by WithCircuitBreaker.
+// The ratio must be in (0, 1], window and cooldown positive and the minimum of requests at least 1.
+func parseCircuitBreaker(transport http.RoundTripper, value string) (*CircuitBreaker, error) {
+	invalid := fmt.Errorf("invalid option %s: '%s'", OptionCircuitBreaker, value)
+
+	parts := strings.Split(value, ",")
+	if len(parts) < 3 {
+		return nil, invalid
+	}
+
+	ratio, err1 := strconv.ParseFloat(parts[0], 64)
+	window, err2 := time.ParseDuration(parts[1])
+	cooldown, err3 := time.ParseDuration(parts[2])
+	if err1 != nil || err2 != nil || err3 != nil {
+		return nil, invalid
+	}
+	// a ratio of 0 would open on success, a window of 0 would never open
+	if ratio <= 0 || ratio > 1 || window <= 0 || cooldown <= 0 {
+		return nil, invalid
+	}
+
+	cb := NewCircuitBreaker(transport, ratio, window, cooldown)
+	if len(parts) > 3 {
+		n, err := strconv.Atoi(parts[3])
+		if err != nil || n < 1 {
+			return nil, invalid
+		}
+		cb.MinRequests = n
+	}
+	return cb, nil
+}
+
+// newBaseTransport returns the transport that sends the requests, configured by the client's options.
+// http.DefaultTransport is used unless an option requires a dedicated transport.
+func newBaseTransport(ds *settings.DialSettings) (http.RoundTripper, error) {
+	tlsConfig, err := newTLSConfig(ds)
+	if err != nil {
+		return nil, err
+	}
+
+	dedicated := tlsConfig != nil
+	for _, opt := range []string{OptionDialTimeout, OptionTLSHandshakeTimeout, OptionResponseHeaderTimeout, OptionMaxIdleConnsPerHost, OptionHTTP2, OptionProxy} {
+		dedicated = dedicated || ds.HasOption(opt)
+	}
+	if !dedicated {
+		return http.DefaultTransport, nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	if d, err := durationOption(ds, OptionDialTimeout); err != nil {
+		return nil, err
+	} else if d > 0 {
+		transport.DialContext = (&net.Dialer{Timeout: d, KeepAlive: 30 * time.Second}).DialContext
+	}
+	if d, err := durationOption(ds, OptionTLSHandshakeTimeout); err != nil {
+		return nil, err
+	} else if d > 0 {
+		transport.TLSHandshakeTimeout = d
+	}
+	if d, err := durationOption(ds, OptionResponseHeaderTimeout); err != nil {
+		return nil, err
+	} else if d > 0 {
+		transport.ResponseHeaderTimeout = d
+	}
+
+	if v := ds.GetOption(OptionMaxIdleConnsPerHost); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid option %s: '%s'", OptionMaxIdleConnsPerHost, v)
+		}
+		transport.MaxIdleConnsPerHost = n
+	}
+
+	if v := ds.GetOption(OptionHTTP2); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid option %s: '%s'", OptionHTTP2, v)
+		}
+		transport.ForceAttemptHTTP2 = enabled
+		if !enabled {
+			// a non-nil, empty map disables HTTP/2
+			transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+		}
+	}
+
+	if ds.HasOption(OptionProxy) {
+		proxy, err := proxyFunc(ds.GetOption(OptionProxy), ds.GetOption(OptionNoProxy))
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = proxy
+	}
+
+	return transport, nil
+}
+
+// durationOption returns the duration stored at key, or 0 if there is none.
+func durationOption(ds *settings.DialSettings, key string) (time.Duration, error) {
+	v := ds.GetOption(key)
+	if v == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid option %s: '%s'", key, v)
+	}
+	return d, nil
+}
+
+// proxyFunc returns a proxy selection that uses proxyURL for all hosts not matching noProxy.
+func proxyFunc(proxyURL, noProxy string) (func(*http.Request) (*url.URL, error), error) {
+	if proxyURL == "" {
+		return nil, nil
+	}
+	proxy, err := url.Parse(proxyURL)
+	if err != nil || proxy.Host == "" {
+		return nil, fmt.Errorf("invalid proxy '%s'", proxyURL)
+	}
+
+	patterns := strings.Split(noProxy, ",")
+	return func(req *http.Request) (*url.URL, error) {
+		if matchNoProxy(req.URL.Hostname(), patterns) {
+			return nil, nil
+		}
+		return proxy, nil
+	}, nil
+}
+
+// matchNoProxy matches host against NO_PROXY style patterns: "*", host names, domain suffixes and CIDRs.
+func matchNoProxy(host string, patterns []string) bool {
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+
+	for _, p := range patterns {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p == "" {
+			continue
+		}
+		if p == "*" {
+			return true
+		}
+		if _, cidr, err := net.ParseCIDR(p); err == nil {
+			if ip != nil && cidr.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if h, _, err := net.SplitHostPort(p); err == nil {
+			p = h
+		}
+		p = strings.TrimPrefix(p, "*")
+		if host == strings.TrimPrefix(p, ".") || strings.HasSuffix(host, "."+strings.TrimPrefix(p, ".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCompression parses "encoding,minsize" as written by WithCompression.
+func parseCompression(value string) (string, int, error) {
+	encoding, size, _ := strings.Cut(value, ",")
+	if _, err := lookupCodec(encoding); err != nil {
+		return "", 0, err
+	}
+	minSize := DefaultCompressionMinSize
+	if size != "" {
+		var err error
+		if minSize, err = strconv.Atoi(size); err != nil || minSize < 0 {
+			return "", 0, fmt.Errorf("invalid option %s: '%s'", OptionCompression, value)
+		}
+	}
+	return encoding, minSize, nil
+}
+
+// checkAcceptEncoding verifies that all content codings in value can be decoded.
+func checkAcceptEncoding(value string) error {
+	for _, encoding := range strings.Split(value, ",") {
+		encoding, _, _ = strings.Cut(encoding, ";") // e.g. gzip;q=0.8
+		if encoding = strings.TrimSpace(encoding); encoding == "identity" || encoding == "*" {
+			continue
+		}
+		if _, err := lookupCodec(encoding); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/txsvc/stdlib/v2/settings"
+)
+
+func TestBaseTransportOptions(t *testing.T) {
+	ds := &settings.DialSettings{}
+	base, err := newBaseTransport(ds)
+	assert.NoError(t, err)
+	assert.Equal(t, http.DefaultTransport, base)
+
+	ds = &settings.DialSettings{}
+	for _, opt := range []settings.Option{
+		WithDialTimeout(time.Second),
+		WithTLSHandshakeTimeout(2 * time.Second),
+		WithResponseHeaderTimeout(3 * time.Second),
+		WithMaxIdleConnsPerHost(16),
+		WithHTTP2(false),
+		WithProxy("http://proxy.local:3128", "localhost", ".internal.example.com", "10.0.0.0/8"),
+	} {
+		opt.Apply(ds)
+	}
+	base, err = newBaseTransport(ds)
+	assert.NoError(t, err)
+
+	transport := base.(*http.Transport)
+	assert.NotSame(t, http.DefaultTransport, transport)
+	assert.Equal(t, 2*time.Second, transport.TLSHandshakeTimeout)
+	assert.Equal(t, 3*time.Second, transport.ResponseHeaderTimeout)
+	assert.Equal(t, 16, transport.MaxIdleConnsPerHost)
+	assert.False(t, transport.ForceAttemptHTTP2)
+	assert.NotNil(t, transport.TLSNextProto)
+
+	proxied := map[string]bool{
+		"https://api.example.com/":       true,
+		"https://localhost:8080/":        false,
+		"https://a.internal.example.com": false,
+		"https://internal.example.com":   false,
+		"http://10.1.2.3/":               false,
+		"http://11.1.2.3/":               true,
+	}
+	for u, want := range proxied {
+		req, _ := http.NewRequest(http.MethodGet, u, nil)
+		proxy, err := transport.Proxy(req)
+		assert.NoError(t, err)
+		if want {
+			assert.Equal(t, &url.URL{Scheme: "http", Host: "proxy.local:3128"}, proxy, u)
+		} else {
+			assert.Nil(t, proxy, u)
+		}
+	}
+
+	// an empty proxy disables HTTP_PROXY etc.
+	ds = &settings.DialSettings{}
+	WithProxy("").Apply(ds)
+	base, err = newBaseTransport(ds)
+	assert.NoError(t, err)
+	assert.Nil(t, base.(*http.Transport).Proxy)
+}
+
+func TestInvalidTransportOptions(t *testing.T) {
+	for _, opt := range []string{OptionTimeout, OptionAttemptTimeout, OptionDialTimeout, OptionMaxIdleConnsPerHost, OptionHTTP2} {
+		_, err := NewRestClient(context.TODO(), WithEndpoint("https://example.com"), optionFunc(func(d *settings.DialSettings) { d.SetOption(opt, "invalid") }))
+		assert.Error(t, err, opt)
+	}
+
+	_, err := NewRestClient(context.TODO(), WithEndpoint("https://example.com"), WithProxy("not a url"))
+	assert.Error(t, err)
+
+	for _, value := range []string{"gzip,x", "gzip,-1"} {
+		_, err := NewRestClient(context.TODO(), WithEndpoint("https://example.com"), optionFunc(func(d *settings.DialSettings) { d.SetOption(OptionCompression, value) }))
+		assert.Error(t, err, value)
+	}
+
+	_, err = NewRestClient(context.TODO(), WithEndpoints(SelectFailover, "https://a.example.com", "https://b.example.com"), optionFunc(func(d *settings.DialSettings) { d.SetOption(OptionHedgeDelay, "invalid") }))
+	assert.Error(t, err)
+
+	for _, value := range []string{"invalid", "0.5,1m", "0.5,1m,x", "0.5,1m,30s,x", "0,1m,30s", "-0.5,1m,30s", "1.5,1m,30s", "0.5,0s,30s", "0.5,1m,0s", "0.5,-1m,30s", "0.5,1m,30s,0"} {
+		_, err := NewRestClient(context.TODO(), WithEndpoint("https://example.com"), optionFunc(func(d *settings.DialSettings) { d.SetOption(OptionCircuitBreaker, value) }))
+		assert.Error(t, err, value)
+	}
+
+	for _, opt := range []string{OptionRateLimit, OptionRateLimit + ":/users"} {
+		_, err := NewRestClient(context.TODO(), WithEndpoint("https://example.com"), optionFunc(func(d *settings.DialSettings) { d.SetOption(opt, "invalid") }))
+		assert.Error(t, err, opt)
+		_, err = NewRestClient(context.TODO(), WithEndpoint("https://example.com"), optionFunc(func(d *settings.DialSettings) { d.SetOption(opt, "1,invalid") }))
+		assert.Error(t, err, opt)
+	}
+}
+
+func TestTimeouts(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 || r.URL.Path == "/slow" {
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL), WithAttemptTimeout(50*time.Millisecond), WithTimeout(300*time.Millisecond))
+	assert.NoError(t, err)
+
+	// the first attempt times out and is retried
+	resp, err := cl.Call(context.TODO(), NewRequest(http.MethodGet, "/"), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, resp.Attempts)
+
+	// the call times out overall
+	start := time.Now()
+	_, err = cl.Call(context.TODO(), NewRequest(http.MethodGet, "/slow"), nil)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), err)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+type optionFunc func(ds *settings.DialSettings)
+
+func (f optionFunc) Apply(ds *settings.DialSettings) {
+	f(ds)
+}
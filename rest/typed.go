@@ -0,0 +1,98 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+)
+
+type (
+	// Validator is implemented by responses that check themselves once decoded.
+	Validator interface {
+		Validate() error
+	}
+
+	// ValidateFunc checks a decoded response.
+	ValidateFunc[T any] func(T) error
+)
+
+var (
+	// ErrInvalidResponse indicates that a response was decoded but failed validation
+	ErrInvalidResponse = errors.New("invalid response")
+)
+
+// Invoke calls r and returns the response decoded as T together with the call's metadata.
+// A T implementing Validator is validated first, unless it is a nil pointer decoded from null,
+// followed by the validate functions.
+// A response failing validation is returned with an *Error wrapping ErrInvalidResponse.
+func Invoke[T any](ctx context.Context, c *RestClient, r *Request, validate ...ValidateFunc[T]) (T, *Response, error) {
+	var v T
+	meta, err := c.Call(ctx, r, &v)
+	if err != nil {
+		return v, meta, err
+	}
+	if meta.StatusCode == http.StatusNoContent {
+		return v, meta, nil // nothing to validate
+	}
+
+	if err := validateResponse(v, validate); err != nil {
+		return v, meta, &Error{StatusCode: meta.StatusCode, RequestID: meta.RequestID, Err: fmt.Errorf("%w: %w", ErrInvalidResponse, err)}
+	}
+	return v, meta, nil
+}
+
+// Get requests path and returns the response decoded as T.
+func Get[T any](ctx context.Context, c *RestClient, path string, validate ...ValidateFunc[T]) (T, error) {
+	v, _, err := Invoke(ctx, c, NewRequest(http.MethodGet, path), validate...)
+	return v, err
+}
+
+// Post sends body to path and returns the response decoded as Resp.
+func Post[Req, Resp any](ctx context.Context, c *RestClient, path string, body Req, validate ...ValidateFunc[Resp]) (Resp, error) {
+	v, _, err := Invoke(ctx, c, NewRequest(http.MethodPost, path).SetBody(body), validate...)
+	return v, err
+}
+
+// Put sends body to path and returns the response decoded as Resp.
+func Put[Req, Resp any](ctx context.Context, c *RestClient, path string, body Req, validate ...ValidateFunc[Resp]) (Resp, error) {
+	v, _, err := Invoke(ctx, c, NewRequest(http.MethodPut, path).SetBody(body), validate...)
+	return v, err
+}
+
+// Patch sends body to path and returns the response decoded as Resp.
+func Patch[Req, Resp any](ctx context.Context, c *RestClient, path string, body Req, validate ...ValidateFunc[Resp]) (Resp, error) {
+	v, _, err := Invoke(ctx, c, NewRequest(http.MethodPatch, path).SetBody(body), validate...)
+	return v, err
+}
+
+// Delete deletes path and returns the response decoded as T, or the zero value of T if there is none.
+func Delete[T any](ctx context.Context, c *RestClient, path string, validate ...ValidateFunc[T]) (T, error) {
+	v, _, err := Invoke(ctx, c, NewRequest(http.MethodDelete, path), validate...)
+	return v, err
+}
+
+func validateResponse[T any](v T, validate []ValidateFunc[T]) error {
+	if validator, ok := any(v).(Validator); ok && !isNilPointer(v) {
+		if err := validator.Validate(); err != nil {
+			return err
+		}
+	} else if validator, ok := any(&v).(Validator); ok {
+		if err := validator.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, fn := range validate {
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isNilPointer reports whether v is a nil pointer, e.g. decoded from a null body
+func isNilPointer(v any) bool {
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Pointer && rv.IsNil()
+}
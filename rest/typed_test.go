@@ -0,0 +1,107 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testUser struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func (u *testUser) Validate() error {
+	if u.ID == "" {
+		return errors.New("missing id")
+	}
+	return nil
+}
+
+func TestTypedHelpers(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /users/1":
+			w.Write([]byte(`{"id":"1","name":"alice"}`))
+		case "GET /users/0":
+			w.Write([]byte(`null`))
+		case "GET /users/2":
+			w.Write([]byte(`{"name":"anonymous"}`))
+		case "POST /users", "PUT /users/1", "PATCH /users/1":
+			w.Write([]byte(`{"id":"1","name":"bob"}`))
+		case "DELETE /users/1":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	cl, err := NewRestClient(context.TODO(), WithEndpoint(srv.URL))
+	assert.NoError(t, err)
+	ctx := context.TODO()
+
+	user, err := Get[testUser](ctx, cl, "/users/1")
+	assert.NoError(t, err)
+	assert.Equal(t, testUser{ID: "1", Name: "alice"}, user)
+
+	// the response validates itself
+	_, err = Get[testUser](ctx, cl, "/users/2")
+	assert.ErrorIs(t, err, ErrInvalidResponse)
+
+	// an additional validation hook
+	_, err = Get(ctx, cl, "/users/1", func(u testUser) error {
+		if u.Name != "bob" {
+			return errors.New("not bob")
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrInvalidResponse)
+	var apiErr *Error
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusOK, apiErr.StatusCode)
+	assert.NotEmpty(t, apiErr.RequestID)
+
+	user, err = Post[testUser, testUser](ctx, cl, "/users", testUser{Name: "bob"})
+	assert.NoError(t, err)
+	assert.Equal(t, "bob", user.Name)
+
+	user, err = Put[testUser, testUser](ctx, cl, "/users/1", testUser{ID: "1", Name: "bob"})
+	assert.NoError(t, err)
+	assert.Equal(t, "bob", user.Name)
+
+	user, err = Patch[map[string]string, testUser](ctx, cl, "/users/1", map[string]string{"name": "bob"})
+	assert.NoError(t, err)
+	assert.Equal(t, "bob", user.Name)
+
+	// no content, no validation
+	deleted, err := Delete[*testUser](ctx, cl, "/users/1")
+	assert.NoError(t, err)
+	assert.Nil(t, deleted)
+
+	// null is not validated by the pointer's Validator, only by the validate functions
+	none, err := Get[*testUser](ctx, cl, "/users/0")
+	assert.NoError(t, err)
+	assert.Nil(t, none)
+
+	_, err = Get(ctx, cl, "/users/0", func(u *testUser) error {
+		if u == nil {
+			return errors.New("no user")
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrInvalidResponse)
+
+	_, err = Get[map[string]any](ctx, cl, "/missing")
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+
+	// the metadata of the call
+	_, meta, err := Invoke[testUser](ctx, cl, NewRequest(http.MethodGet, "/users/{id}").SetParam("id", "1"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, meta.Attempts)
+}
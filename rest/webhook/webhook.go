@@ -0,0 +1,318 @@
+// Package webhook receives webhooks signed with the shared secret of settings.Credentials.
+//
+// Signatures follow the Standard Webhooks scheme (https://www.standardwebhooks.com): a delivery
+// carries the headers webhook-id, webhook-timestamp and webhook-signature, the latter containing
+// "v1,<base64 HMAC-SHA256 of id.timestamp.body>". Several space-separated signatures are accepted,
+// e.g. while the secret is rotated. A secret in the scheme's format "whsec_<base64 key>" is decoded
+// to its key, any other secret is used as it is.
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/txsvc/stdlib/v2"
+	"github.com/txsvc/stdlib/v2/settings"
+)
+
+const (
+	HeaderID        = "webhook-id"
+	HeaderTimestamp = "webhook-timestamp"
+	HeaderSignature = "webhook-signature"
+
+	// DefaultTolerance is the maximum age, and clock skew, of a delivery
+	DefaultTolerance = 5 * time.Minute
+	// DefaultMaxBodySize limits the size of a payload
+	DefaultMaxBodySize = 1 << 20
+
+	// pruneInterval is how often a MemoryNonceCache drops expired IDs
+	pruneInterval = time.Minute
+
+	signatureVersion = "v1"
+	secretPrefix     = "whsec_"
+)
+
+type (
+	// Event is a verified delivery. Payloads are expected as {"type": "...", "data": {...}}.
+	Event struct {
+		ID        string      `json:"-"` // the delivery ID, unique per event
+		Timestamp time.Time   `json:"-"`
+		Type      string      `json:"type"`
+		Data      interface{} `json:"data"` // as decoded by stdlib.Unmarshal, unless a typed handler decodes it
+		raw       []byte
+	}
+
+	// HandlerFunc handles a verified event. An error makes the sender deliver the event again.
+	HandlerFunc func(ctx context.Context, e *Event) error
+
+	// NonceCache remembers delivery IDs in order to reject replays.
+	NonceCache interface {
+		// Add records id until expires and reports false if it is already known
+		Add(id string, expires time.Time) bool
+		// Remove forgets id, e.g. after its delivery failed and will be retried
+		Remove(id string)
+	}
+
+	// MemoryNonceCache is a NonceCache kept in memory.
+	MemoryNonceCache struct {
+		mu     sync.Mutex
+		nonces map[string]time.Time
+		pruned time.Time
+	}
+
+	// Receiver is an http.Handler that verifies deliveries and dispatches them by event type.
+	// Events without a handler are acknowledged and dropped.
+	Receiver struct {
+		Tolerance   time.Duration
+		MaxBodySize int64
+		Nonces      NonceCache
+
+		secret   []byte
+		handlers map[string]HandlerFunc
+	}
+)
+
+var (
+	// ErrMissingSecret is returned by NewReceiver if the credentials have no secret
+	ErrMissingSecret = errors.New("missing client secret")
+	// ErrInvalidSecret is returned by NewReceiver for a "whsec_" secret that is not base64 encoded
+	ErrInvalidSecret = errors.New("invalid client secret")
+	// ErrInvalidSignature indicates a delivery that was not signed with the shared secret
+	ErrInvalidSignature = errors.New("invalid signature")
+	// ErrTimestampOutOfRange indicates a delivery that is too old, or from the future
+	ErrTimestampOutOfRange = errors.New("timestamp out of range")
+	// ErrReplay indicates a delivery ID that was seen before
+	ErrReplay = errors.New("replayed delivery")
+)
+
+// NewReceiver returns a receiver that verifies deliveries with the client secret of cred.
+func NewReceiver(cred *settings.Credentials) (*Receiver, error) {
+	if cred == nil || cred.ClientSecret == "" {
+		return nil, ErrMissingSecret
+	}
+	secret, err := secretKey(cred.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	return &Receiver{
+		Tolerance:   DefaultTolerance,
+		MaxBodySize: DefaultMaxBodySize,
+		Nonces:      NewMemoryNonceCache(),
+		secret:      secret,
+		handlers:    make(map[string]HandlerFunc),
+	}, nil
+}
+
+// Handle registers the handler of eventType.
+func (r *Receiver) Handle(eventType string, h HandlerFunc) {
+	r.handlers[eventType] = h
+}
+
+// On registers a handler of eventType that receives the event's data decoded as T.
+func On[T any](r *Receiver, eventType string, fn func(ctx context.Context, e *Event, data T) error) {
+	r.Handle(eventType, func(ctx context.Context, e *Event) error {
+		var data T
+		if err := e.Decode(&data); err != nil {
+			return err
+		}
+		return fn(ctx, e, data)
+	})
+}
+
+// Decode unmarshals the event's data into v.
+func (e *Event) Decode(v interface{}) error {
+	envelope := struct {
+		Data interface{} `json:"data"`
+	}{Data: v}
+	return stdlib.Unmarshal(e.raw, &envelope)
+}
+
+// ServeHTTP verifies and dispatches a delivery.
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, r.maxBodySize()+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if int64(len(body)) > r.maxBodySize() {
+		http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	e, err := r.Verify(req.Header, body)
+	if errors.Is(err, ErrReplay) {
+		// a redelivery of a processed event is acknowledged, the sender would retry it otherwise
+		log.Debug().Str("id", req.Header.Get(HeaderID)).Msg("WEBHOOK duplicate")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err != nil {
+		log.Warn().Err(err).Str("id", req.Header.Get(HeaderID)).Msg("WEBHOOK")
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if err := stdlib.Unmarshal(body, e); err != nil {
+		r.forget(e)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	e.raw = body
+
+	h, ok := r.handlers[e.Type]
+	if !ok {
+		log.Debug().Str("id", e.ID).Str("type", e.Type).Msg("WEBHOOK unhandled")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err := h(req.Context(), e); err != nil {
+		log.Error().Err(err).Str("id", e.ID).Str("type", e.Type).Msg("WEBHOOK")
+		r.forget(e) // the sender retries
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	log.Debug().Str("id", e.ID).Str("type", e.Type).Msg("WEBHOOK")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Verify checks the signature, timestamp and delivery ID of a delivery and returns its event, without the payload.
+func (r *Receiver) Verify(h http.Header, body []byte) (*Event, error) {
+	id := h.Get(HeaderID)
+	ts := h.Get(HeaderTimestamp)
+	if id == "" || ts == "" || h.Get(HeaderSignature) == "" {
+		return nil, fmt.Errorf("%w: missing headers", ErrInvalidSignature)
+	}
+
+	seconds, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: '%s'", ErrTimestampOutOfRange, ts)
+	}
+	timestamp := time.Unix(seconds, 0)
+	now := stdlib.GetClock().Now()
+	tolerance := r.Tolerance
+	if tolerance <= 0 {
+		tolerance = DefaultTolerance
+	}
+	if timestamp.Before(now.Add(-tolerance)) || timestamp.After(now.Add(tolerance)) {
+		return nil, ErrTimestampOutOfRange
+	}
+
+	expected := signature(r.secret, id, seconds, body)
+	valid := false
+	for _, sig := range strings.Fields(h.Get(HeaderSignature)) {
+		version, value, _ := strings.Cut(sig, ",")
+		if version == signatureVersion && hmac.Equal([]byte(value), []byte(expected)) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return nil, ErrInvalidSignature
+	}
+
+	// only authentic deliveries are remembered, for as long as they would be accepted
+	if r.Nonces != nil && !r.Nonces.Add(id, timestamp.Add(tolerance)) {
+		return nil, fmt.Errorf("%w: '%s'", ErrReplay, id)
+	}
+	return &Event{ID: id, Timestamp: timestamp}, nil
+}
+
+func (r *Receiver) forget(e *Event) {
+	if r.Nonces != nil {
+		r.Nonces.Remove(e.ID)
+	}
+}
+
+func (r *Receiver) maxBodySize() int64 {
+	if r.MaxBodySize <= 0 {
+		return DefaultMaxBodySize
+	}
+	return r.MaxBodySize
+}
+
+// Sign returns the headers of a delivery of body, e.g. to send webhooks or to test a receiver.
+func Sign(cred *settings.Credentials, id string, timestamp time.Time, body []byte) http.Header {
+	h := make(http.Header)
+	h.Set(HeaderID, id)
+	h.Set(HeaderTimestamp, strconv.FormatInt(timestamp.Unix(), 10))
+	secret, err := secretKey(cred.ClientSecret)
+	if err != nil {
+		secret = []byte(cred.ClientSecret) // NewReceiver rejects it
+	}
+	h.Set(HeaderSignature, signatureVersion+","+signature(secret, id, timestamp.Unix(), body))
+	return h
+}
+
+// secretKey returns the HMAC key of secret, the decoded key of a "whsec_" secret
+func secretKey(secret string) ([]byte, error) {
+	encoded, ok := strings.CutPrefix(secret, secretPrefix)
+	if !ok {
+		return []byte(secret), nil
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) == 0 {
+		return nil, ErrInvalidSecret
+	}
+	return key, nil
+}
+
+func signature(secret []byte, id string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%s.%d.", id, timestamp)
+	mac.Write(body)
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// NewMemoryNonceCache returns an empty nonce cache.
+func NewMemoryNonceCache() *MemoryNonceCache {
+	return &MemoryNonceCache{
+		nonces: make(map[string]time.Time),
+	}
+}
+
+// Add implements NonceCache. Expired IDs are dropped every minute along the way.
+func (c *MemoryNonceCache) Add(id string, expires time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := stdlib.GetClock().Now()
+	if now.Sub(c.pruned) >= pruneInterval {
+		for k, exp := range c.nonces {
+			if now.After(exp) {
+				delete(c.nonces, k)
+			}
+		}
+		c.pruned = now
+	}
+
+	if exp, ok := c.nonces[id]; ok && !now.After(exp) {
+		return false
+	}
+	c.nonces[id] = expires
+	return true
+}
+
+// Remove implements NonceCache.
+func (c *MemoryNonceCache) Remove(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.nonces, id)
+}
@@ -0,0 +1,188 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/txsvc/stdlib/v2"
+	"github.com/txsvc/stdlib/v2/settings"
+)
+
+type invoicePaid struct {
+	Invoice string      `json:"invoice"`
+	Amount  json.Number `json:"amount"`
+}
+
+func deliver(t *testing.T, r http.Handler, h http.Header, body []byte) int {
+	req := httptest.NewRequest(http.MethodPost, "/webhooks", bytes.NewReader(body))
+	for k, v := range h {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestReceiver(t *testing.T) {
+	cred := &settings.Credentials{ClientID: "client", ClientSecret: "secret"}
+	r, err := NewReceiver(cred)
+	assert.NoError(t, err)
+
+	var paid []invoicePaid
+	On(r, "invoice.paid", func(ctx context.Context, e *Event, data invoicePaid) error {
+		assert.Equal(t, "msg_1", e.ID)
+		paid = append(paid, data)
+		return nil
+	})
+	failures := 0
+	r.Handle("invoice.failed", func(ctx context.Context, e *Event) error {
+		failures++
+		if failures == 1 {
+			return errors.New("try again")
+		}
+		assert.Equal(t, map[string]interface{}{"invoice": "inv_2"}, e.Data)
+		return nil
+	})
+
+	body := []byte(`{"type":"invoice.paid","data":{"invoice":"inv_1","amount":42.5}}`)
+	now := time.Now()
+
+	assert.Equal(t, http.StatusNoContent, deliver(t, r, Sign(cred, "msg_1", now, body), body))
+	assert.Equal(t, []invoicePaid{{Invoice: "inv_1", Amount: "42.5"}}, paid)
+
+	// a redelivery is acknowledged, but not handled again
+	assert.Equal(t, http.StatusNoContent, deliver(t, r, Sign(cred, "msg_1", now, body), body))
+	assert.Len(t, paid, 1)
+
+	// a failed handler lets the sender retry
+	body = []byte(`{"type":"invoice.failed","data":{"invoice":"inv_2"}}`)
+	assert.Equal(t, http.StatusInternalServerError, deliver(t, r, Sign(cred, "msg_2", now, body), body))
+	assert.Equal(t, http.StatusNoContent, deliver(t, r, Sign(cred, "msg_2", now, body), body))
+	assert.Equal(t, 2, failures)
+
+	// unknown event types are acknowledged
+	body = []byte(`{"type":"invoice.created","data":{}}`)
+	assert.Equal(t, http.StatusNoContent, deliver(t, r, Sign(cred, "msg_3", now, body), body))
+
+	// not JSON
+	body = []byte(`invoice`)
+	assert.Equal(t, http.StatusBadRequest, deliver(t, r, Sign(cred, "msg_4", now, body), body))
+}
+
+func TestVerify(t *testing.T) {
+	cred := &settings.Credentials{ClientSecret: "secret"}
+	r, err := NewReceiver(cred)
+	assert.NoError(t, err)
+	now := time.Unix(1700000000, 0)
+	stdlib.SetClock(stdlib.NewFakeClock(now))
+	defer stdlib.SetClock(nil)
+
+	body := []byte(`{"type":"ping"}`)
+	h := Sign(cred, "msg_1", now, body)
+
+	// tampered body
+	_, err = r.Verify(h, []byte(`{"type":"pong"}`))
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	// wrong secret, but a rotated secret may sign as well
+	other := Sign(&settings.Credentials{ClientSecret: "other"}, "msg_1", now, body)
+	_, err = r.Verify(other, body)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+	other.Set(HeaderSignature, other.Get(HeaderSignature)+" "+h.Get(HeaderSignature))
+	e, err := r.Verify(other, body)
+	assert.NoError(t, err)
+	assert.Equal(t, "msg_1", e.ID)
+	assert.Equal(t, now, e.Timestamp)
+
+	// too old and from the future
+	_, err = r.Verify(Sign(cred, "msg_2", now.Add(-10*time.Minute), body), body)
+	assert.ErrorIs(t, err, ErrTimestampOutOfRange)
+	_, err = r.Verify(Sign(cred, "msg_3", now.Add(10*time.Minute), body), body)
+	assert.ErrorIs(t, err, ErrTimestampOutOfRange)
+
+	// missing headers
+	_, err = r.Verify(http.Header{}, body)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	_, err = NewReceiver(&settings.Credentials{Token: "token"})
+	assert.ErrorIs(t, err, ErrMissingSecret)
+}
+
+func TestStandardWebhooksSecret(t *testing.T) {
+	// the example of the Standard Webhooks specification
+	cred := &settings.Credentials{ClientSecret: "whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw"}
+	body := []byte(`{"test": 2432232314}`)
+	timestamp := time.Unix(1614265330, 0)
+
+	h := Sign(cred, "msg_p5jXN8AQM9LWM0D4loKWxJek", timestamp, body)
+	assert.Equal(t, "v1,g0hM9SsE+OTPJTGt/tmIKtSyZlE3uFJELVlNIOLJ1OE=", h.Get(HeaderSignature))
+
+	stdlib.SetClock(stdlib.NewFakeClock(timestamp))
+	defer stdlib.SetClock(nil)
+
+	r, err := NewReceiver(cred)
+	assert.NoError(t, err)
+	_, err = r.Verify(h, body)
+	assert.NoError(t, err)
+
+	_, err = NewReceiver(&settings.Credentials{ClientSecret: "whsec_not base64"})
+	assert.ErrorIs(t, err, ErrInvalidSecret)
+}
+
+func TestReceiverLimits(t *testing.T) {
+	cred := &settings.Credentials{ClientSecret: "secret"}
+	r, err := NewReceiver(cred)
+	assert.NoError(t, err)
+	r.MaxBodySize = 16
+
+	body := []byte(`{"type":"ping","data":"too large"}`)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, deliver(t, r, Sign(cred, "msg_1", time.Now(), body), body))
+
+	req := httptest.NewRequest(http.MethodGet, "/webhooks", nil)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestMemoryNonceCache(t *testing.T) {
+	c := NewMemoryNonceCache()
+	assert.True(t, c.Add("a", time.Now().Add(time.Minute)))
+	assert.False(t, c.Add("a", time.Now().Add(time.Minute)))
+
+	c.Remove("a")
+	assert.True(t, c.Add("a", time.Now().Add(time.Minute)))
+
+	// expired IDs are dropped
+	assert.True(t, c.Add("b", time.Now().Add(-time.Second)))
+	assert.True(t, c.Add("b", time.Now().Add(time.Minute)))
+}
+
+func TestMemoryNonceCacheClock(t *testing.T) {
+	clock := stdlib.NewFakeClock(time.Unix(1700000000, 0))
+	stdlib.SetClock(clock)
+	defer stdlib.SetClock(nil)
+
+	c := NewMemoryNonceCache()
+	assert.True(t, c.Add("a", clock.Now().Add(10*time.Second)))
+	assert.True(t, c.Add("b", clock.Now().Add(2*time.Minute)))
+
+	// an expired ID is accepted again, even before it is pruned
+	clock.Advance(20 * time.Second)
+	assert.True(t, c.Add("a", clock.Now().Add(10*time.Second)))
+	assert.False(t, c.Add("b", clock.Now().Add(10*time.Second)))
+	assert.Len(t, c.nonces, 2)
+
+	// expired IDs are pruned once a minute
+	clock.Advance(time.Minute)
+	assert.True(t, c.Add("c", clock.Now().Add(time.Minute)))
+	assert.Len(t, c.nonces, 2)
+	assert.NotContains(t, c.nonces, "a")
+}
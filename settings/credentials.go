@@ -76,7 +76,8 @@ func (c *Credentials) IsValid() bool {
 	return !c.Expired()
 }
 
-// Expired only verifies just that, does not check all other attributes
+// Expired only verifies just that, does not check all other attributes.
+// It compares with stdlib.Now, i.e. the clock set with stdlib.SetClock.
 func (c *Credentials) Expired() bool {
 	if c.Expires == 0 {
 		return false
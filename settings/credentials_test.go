@@ -3,6 +3,7 @@ package settings
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -70,6 +71,27 @@ func TestExpiration(t *testing.T) {
 	assert.False(t, cred.Expired())
 }
 
+func TestExpirationWithClock(t *testing.T) {
+	clock := stdlib.NewFakeClock(time.Unix(1621003682, 0))
+	stdlib.SetClock(clock)
+	defer stdlib.SetClock(nil)
+
+	cred := Credentials{
+		ClientID: "c",
+		Token:    "t",
+		Expires:  stdlib.IncT(stdlib.Now(), 60),
+	}
+	assert.False(t, cred.Expired())
+	assert.True(t, cred.IsValid())
+
+	clock.Advance(59 * time.Minute)
+	assert.False(t, cred.Expired())
+
+	clock.Advance(61 * time.Second)
+	assert.True(t, cred.Expired())
+	assert.False(t, cred.IsValid())
+}
+
 func TestCredentialsFromEnvWithClientCredentials(t *testing.T) {
 	expectedProjectID := "test-project-123"
 	expectedClientID := "test-client-456"
@@ -1,17 +1,24 @@
 package stdlib
 
 import (
+	"fmt"
+	"sync"
 	"time"
 )
 
+var (
+	// locations caches the time zones loaded by Location
+	locations sync.Map
+)
+
 // Now returns the curent time in seconds UTC
 func Now() int64 {
-	return time.Now().Unix()
+	return GetClock().Now().Unix()
 }
 
 // Nano returns the curent time in miliseconds UTC
 func Nano() int64 {
-	return time.Now().UnixNano()
+	return GetClock().Now().UnixNano()
 }
 
 // IncT increments a timstamp (in seconds) by m minutes.
@@ -21,7 +28,7 @@ func IncT(t int64, m int) int64 {
 
 // ElapsedTimeSince returns the difference between t and now.
 func ElapsedTimeSince(t time.Time) int64 {
-	d := time.Since(t)
+	d := GetClock().Since(t)
 	return (int64)(d / time.Millisecond)
 }
 
@@ -44,3 +51,89 @@ func ToHour(t int64) int {
 func ToHourUTC(t int64) int {
 	return time.Unix(t, 0).UTC().Hour()
 }
+
+// Location returns the time zone of an IANA name like "Europe/Berlin". An empty name and "UTC" return UTC, "Local" the local time zone.
+func Location(name string) (*time.Location, error) {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// ToTime converts a timestamp to a time.Time in loc, UTC if loc is nil.
+func ToTime(t int64, loc *time.Location) time.Time {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return time.Unix(t, 0).In(loc)
+}
+
+// ToHourIn retuns the hour of the day for the timestamp, in loc
+func ToHourIn(t int64, loc *time.Location) int {
+	return ToTime(t, loc).Hour()
+}
+
+// ToWeekdayIn retuns the day of the week for the timestamp, in loc
+func ToWeekdayIn(t int64, loc *time.Location) int {
+	return int(ToTime(t, loc).Weekday())
+}
+
+// StartOfDay returns the timestamp of midnight of the timestamp's day in loc.
+func StartOfDay(t int64, loc *time.Location) int64 {
+	tt := ToTime(t, loc)
+	return time.Date(tt.Year(), tt.Month(), tt.Day(), 0, 0, 0, 0, tt.Location()).Unix()
+}
+
+// EndOfDay returns the timestamp of the last second of the timestamp's day in loc.
+func EndOfDay(t int64, loc *time.Location) int64 {
+	tt := ToTime(t, loc)
+	return time.Date(tt.Year(), tt.Month(), tt.Day()+1, 0, 0, 0, 0, tt.Location()).Unix() - 1
+}
+
+// StartOfWeek returns the timestamp of midnight of the Monday of the timestamp's week in loc, weeks start on Monday as in ISO 8601.
+func StartOfWeek(t int64, loc *time.Location) int64 {
+	tt := ToTime(t, loc)
+	offset := (int(tt.Weekday()) + 6) % 7 // days since Monday
+	return time.Date(tt.Year(), tt.Month(), tt.Day()-offset, 0, 0, 0, 0, tt.Location()).Unix()
+}
+
+// EndOfWeek returns the timestamp of the last second of the Sunday of the timestamp's week in loc.
+func EndOfWeek(t int64, loc *time.Location) int64 {
+	tt := ToTime(t, loc)
+	offset := (int(tt.Weekday()) + 6) % 7
+	return time.Date(tt.Year(), tt.Month(), tt.Day()-offset+7, 0, 0, 0, 0, tt.Location()).Unix() - 1
+}
+
+// StartOfMonth returns the timestamp of midnight of the first day of the timestamp's month in loc.
+func StartOfMonth(t int64, loc *time.Location) int64 {
+	tt := ToTime(t, loc)
+	return time.Date(tt.Year(), tt.Month(), 1, 0, 0, 0, 0, tt.Location()).Unix()
+}
+
+// EndOfMonth returns the timestamp of the last second of the timestamp's month in loc.
+func EndOfMonth(t int64, loc *time.Location) int64 {
+	tt := ToTime(t, loc)
+	return time.Date(tt.Year(), tt.Month()+1, 1, 0, 0, 0, 0, tt.Location()).Unix() - 1
+}
+
+// FormatRFC3339 formats the timestamp as RFC 3339 in loc, e.g. 2021-05-14T16:48:02+02:00.
+func FormatRFC3339(t int64, loc *time.Location) string {
+	return ToTime(t, loc).Format(time.RFC3339)
+}
+
+// FormatISOWeek formats the timestamp as ISO 8601 week date in loc, e.g. 2021-W19-5.
+func FormatISOWeek(t int64, loc *time.Location) string {
+	tt := ToTime(t, loc)
+	year, week := tt.ISOWeek()
+	return fmt.Sprintf("%04d-W%02d-%d", year, week, (int(tt.Weekday())+6)%7+1)
+}
+
+// Format formats the timestamp with a layout of package time in loc.
+func Format(t int64, loc *time.Location, layout string) string {
+	return ToTime(t, loc).Format(layout)
+}
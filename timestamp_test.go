@@ -69,3 +69,82 @@ func TestToHour(t *testing.T) {
 	assert.Equal(t, knownHour, hour)
 	assert.NotEqual(t, hour, utc)
 }
+
+func TestLocation(t *testing.T) {
+	loc, err := Location("Europe/Berlin")
+	assert.NoError(t, err)
+	assert.Equal(t, "Europe/Berlin", loc.String())
+
+	cached, err := Location("Europe/Berlin")
+	assert.NoError(t, err)
+	assert.Same(t, loc, cached)
+
+	loc, err = Location("")
+	assert.NoError(t, err)
+	assert.Equal(t, time.UTC, loc)
+
+	_, err = Location("Mars/Olympus_Mons")
+	assert.Error(t, err)
+}
+
+func TestInLocation(t *testing.T) {
+	berlin, err := Location("Europe/Berlin")
+	assert.NoError(t, err)
+	tokyo, err := Location("Asia/Tokyo")
+	assert.NoError(t, err)
+
+	assert.Equal(t, knownHour, ToHourIn(knownNow, berlin))
+	assert.Equal(t, 14, ToHourIn(knownNow, nil))
+	assert.Equal(t, 23, ToHourIn(knownNow, tokyo))
+	assert.Equal(t, knownWeekDay, ToWeekdayIn(knownNow, berlin))
+
+	// 00:48:02 on Saturday in Tokyo
+	late := int64(knownNow + 3600)
+	assert.Equal(t, 6, ToWeekdayIn(late, tokyo))
+	assert.Equal(t, knownWeekDay, ToWeekdayIn(late, berlin))
+}
+
+func TestStartEnd(t *testing.T) {
+	berlin, err := Location("Europe/Berlin")
+	assert.NoError(t, err)
+
+	format := func(t int64) string {
+		return Format(t, berlin, "2006-01-02 15:04:05 Mon")
+	}
+
+	assert.Equal(t, "2021-05-14 00:00:00 Fri", format(StartOfDay(knownNow, berlin)))
+	assert.Equal(t, "2021-05-14 23:59:59 Fri", format(EndOfDay(knownNow, berlin)))
+	assert.Equal(t, "2021-05-10 00:00:00 Mon", format(StartOfWeek(knownNow, berlin)))
+	assert.Equal(t, "2021-05-16 23:59:59 Sun", format(EndOfWeek(knownNow, berlin)))
+	assert.Equal(t, "2021-05-01 00:00:00 Sat", format(StartOfMonth(knownNow, berlin)))
+	assert.Equal(t, "2021-05-31 23:59:59 Mon", format(EndOfMonth(knownNow, berlin)))
+
+	// midnight in Berlin is 22:00 UTC the day before
+	assert.Equal(t, int64(1620943200), StartOfDay(knownNow, berlin))
+	assert.Equal(t, int64(1620950400), StartOfDay(knownNow, nil))
+
+	// a Sunday belongs to the week that started on Monday
+	sunday := EndOfWeek(knownNow, berlin)
+	assert.Equal(t, StartOfWeek(knownNow, berlin), StartOfWeek(sunday, berlin))
+
+	// the day clocks are put forward has 23 hours
+	dst := time.Date(2021, 3, 28, 12, 0, 0, 0, berlin).Unix()
+	assert.Equal(t, int64(23*3600), EndOfDay(dst, berlin)-StartOfDay(dst, berlin)+1)
+	assert.Equal(t, int64(24*3600), EndOfDay(dst, nil)-StartOfDay(dst, nil)+1)
+}
+
+func TestFormat(t *testing.T) {
+	berlin, err := Location("Europe/Berlin")
+	assert.NoError(t, err)
+
+	assert.Equal(t, "2021-05-14T16:48:02+02:00", FormatRFC3339(knownNow, berlin))
+	assert.Equal(t, "2021-05-14T14:48:02Z", FormatRFC3339(knownNow, nil))
+	assert.Equal(t, "2021-W19-5", FormatISOWeek(knownNow, berlin))
+	assert.Equal(t, "14.05.2021 16:48", Format(knownNow, berlin, "02.01.2006 15:04"))
+
+	// January 1st, 2021 was a Friday and belongs to the last week of 2020
+	newYear := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC).Unix()
+	assert.Equal(t, "2020-W53-5", FormatISOWeek(newYear, nil))
+	// a Sunday is the 7th day
+	assert.Equal(t, "2021-W19-7", FormatISOWeek(EndOfWeek(knownNow, berlin), berlin))
+}